@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/federicoReghini/Chirpy/internal/database"
+	"github.com/federicoReghini/Chirpy/internal/moderation"
 	"github.com/google/uuid"
 )
 
@@ -13,8 +17,12 @@ import (
 // It reads the request body, validates the chirp, and if valid, inserts it into the database.
 // If the chirp is invalid, it returns an error response.
 // If the chirp is valid, it returns the created chirp with a 201 status code.
-// It also censors bad words in the chirp body.
+// It also applies the moderation rules to the chirp body (see handlerValidateChirp).
 // The chirp body must not exceed 140 characters.
+// The optional in_reply_to field makes the chirp a reply to an existing chirp.
+// The optional quote_of field makes the chirp a quote chirp of an existing chirp.
+// Hashtags (#tag) and mentions (@username) in the body are saved with the chirp.
+// The optional media_ids field attaches up to 4 images uploaded with POST /api/media, in that order.
 // The function uses the database.CreateChirpParams struct to validate the chirp parameters.
 // It returns a JSON response with the created chirp or an error message.
 // The function is part of the apiConfig struct which contains the database connection.
@@ -22,23 +30,88 @@ import (
 func (c *apiConfig) handlerCreateChirp(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
 
-	userID, err := getUserIDFromValidateJWT(c, w, req)
+	userID := userIDFromContext(req.Context())
+
+	// Check if length of the chirp is valid
+	chirpValidated, moderationResult, isValid := c.handlerValidateChirp(w, req)
+	if !isValid {
+		return
+	}
+
+	chirpValidated.UserID = uuid.NullUUID{UUID: userID, Valid: true}
+
+	chirpValidated.Kind = "chirp"
+
+	if chirpValidated.InReplyTo.Valid {
+		parent, err := c.db.GetChirp(req.Context(), chirpValidated.InReplyTo.UUID)
+		if err != nil {
+			marshalError(w, http.StatusBadRequest, "Chirp to reply to not found")
+			return
+		}
+		chirpValidated.InReplyTo = originalChirpID(parent)
+	}
+
+	if chirpValidated.QuoteOf.Valid {
+		quoted, err := c.db.GetChirp(req.Context(), chirpValidated.QuoteOf.UUID)
+		if err != nil {
+			marshalError(w, http.StatusBadRequest, "Chirp to quote not found")
+			return
+		}
+		if chirpValidated.Body == "" {
+			marshalError(w, http.StatusBadRequest, "A quote chirp must have a body")
+			return
+		}
+		chirpValidated.QuoteOf = originalChirpID(quoted)
+		chirpValidated.Kind = "quote"
+	}
+
+	if len(chirpValidated.MediaIDs) > maxChirpMedia {
+		marshalError(w, http.StatusBadRequest, "Too many media attached")
+		return
+	}
+
+	// The chirp, its hashtags, mentions and media are saved together
+	tx, err := c.sqlDB.BeginTx(req.Context(), nil)
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := c.db.WithTx(tx)
+
+	dbChirp, err := qtx.CreateChirp(req.Context(), chirpValidated.CreateChirpParams)
 
 	if err != nil {
-		marshalError(w, http.StatusUnauthorized, err.Error())
+		marshalError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	// Check if length of the chirp is valid
-	chirpValidated, isValid := handlerValidateChirp(w, req)
-	if !isValid {
+	if err := indexChirpEntities(req.Context(), qtx, dbChirp); err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	chirpValidated.UserID = uuid.NullUUID{UUID: userID, Valid: true}
+	if err := flagChirp(req.Context(), qtx, dbChirp.ID, moderationResult); err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	attached, err := attachChirpMedia(req.Context(), qtx, dbChirp, chirpValidated.MediaIDs)
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !attached {
+		marshalError(w, http.StatusBadRequest, "Media not found or already attached")
+		return
+	}
 
-	chirp, err := c.db.CreateChirp(req.Context(), chirpValidated)
+	if err := tx.Commit(); err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 
+	chirp, err := c.newChirpResponse(req.Context(), dbChirp, uuid.NullUUID{UUID: userID, Valid: true})
 	if err != nil {
 		marshalError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -47,104 +120,177 @@ func (c *apiConfig) handlerCreateChirp(w http.ResponseWriter, req *http.Request)
 	marshalOkJson(w, http.StatusCreated, chirp)
 }
 
+// chirpsPage is the response envelope for paginated chirp listings.
+// NextCursor is only set when HasMore is true and must be passed back
+// as the "cursor" query parameter to fetch the following page.
+type chirpsPage struct {
+	Chirps     []chirpResponse `json:"chirps"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+	HasMore    bool            `json:"has_more"`
+}
+
+// newChirpsPage builds the response envelope from a query that fetched limit+1 rows.
+// The extra row is only used to know if another page exists and is dropped.
+func (c *apiConfig) newChirpsPage(ctx context.Context, dbChirps []database.Chirp, limit int32, viewerID uuid.NullUUID) (chirpsPage, error) {
+	page := chirpsPage{}
+
+	if len(dbChirps) > int(limit) {
+		dbChirps = dbChirps[:limit]
+		page.HasMore = true
+	}
+
+	chirps, err := c.newChirpResponses(ctx, dbChirps, viewerID)
+	if err != nil {
+		return chirpsPage{}, err
+	}
+	page.Chirps = chirps
+
+	if page.HasMore {
+		last := dbChirps[len(dbChirps)-1]
+		page.NextCursor = encodeCursor(chirpCursor{
+			CreatedAt: last.CreatedAt,
+			ID:        last.ID,
+		})
+	}
+
+	return page, nil
+}
+
+// handlerGetChips returns a page of chirps.
+// Query parameters:
+//   - sort: "asc" (default) or "desc", ordered by creation time
+//   - author_id: only return chirps of this user
+//   - limit: page size, default 20, max 100
+//   - cursor: the next_cursor returned by the previous page
 func (c *apiConfig) handlerGetChips(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
 
-	sort := req.URL.Query().Get("sort")
+	query := req.URL.Query()
+
+	sort := query.Get("sort")
 	if sort == "" {
 		sort = "asc"
 	}
-	dbChirps, err := c.db.GetChirps(req.Context(), sort)
+	if sort != "asc" && sort != "desc" {
+		marshalError(w, http.StatusBadRequest, "Invalid sort, must be asc or desc")
+		return
+	}
 
-	authorID := uuid.Nil
-	authorIDString := req.URL.Query().Get("author_id")
-	if authorIDString != "" {
-		authorID, err = uuid.Parse(authorIDString)
+	authorID := uuid.NullUUID{}
+	if authorIDString := query.Get("author_id"); authorIDString != "" {
+		parsedID, err := uuid.Parse(authorIDString)
 		if err != nil {
 			marshalError(w, http.StatusBadRequest, "Invalid author ID")
 			return
 		}
+		authorID = uuid.NullUUID{UUID: parsedID, Valid: true}
 	}
 
-	chirps := []database.Chirp{}
-	for _, dbChirp := range dbChirps {
-		if authorID != uuid.Nil && dbChirp.UserID.UUID != authorID {
-			continue
-		}
+	limit, err := parsePageLimit(query)
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cursor, err := parseChirpCursor(query)
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cursorCreatedAt, cursorID := cursor.queryParams()
 
-		chirps = append(chirps, database.Chirp{
-			ID:        dbChirp.ID,
-			CreatedAt: dbChirp.CreatedAt,
-			UpdatedAt: dbChirp.UpdatedAt,
-			UserID:    dbChirp.UserID,
-			Body:      dbChirp.Body,
+	var dbChirps []database.Chirp
+	if sort == "asc" {
+		dbChirps, err = c.db.ListChirpsAsc(req.Context(), database.ListChirpsAscParams{
+			AuthorID:        authorID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageLimit:       limit + 1,
 		})
+	} else {
+		dbChirps, err = c.db.ListChirpsDesc(req.Context(), database.ListChirpsDescParams{
+			AuthorID:        authorID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageLimit:       limit + 1,
+		})
+	}
+
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 
+	page, err := c.newChirpsPage(req.Context(), dbChirps, limit, optionalUserIDFromContext(req.Context()))
 	if err != nil {
 		marshalError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	marshalOkJson(w, http.StatusOK, chirps)
+	marshalOkJson(w, http.StatusOK, page)
 }
+
 func (c *apiConfig) handlerGetChipByID(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
 
-	uuid, err := uuid.Parse(req.PathValue("chirpID"))
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
 
 	if err != nil {
 		marshalError(w, http.StatusBadRequest, "Invalid chirp ID")
 		return
 	}
 
-	chirp, err := c.db.GetChirp(req.Context(), uuid)
+	dbChirp, err := c.db.GetChirp(req.Context(), chirpID)
 
 	if err != nil {
 		marshalError(w, http.StatusNotFound, "Chirp not found")
 		return
 	}
+
+	chirp, err := c.newChirpResponse(req.Context(), dbChirp, optionalUserIDFromContext(req.Context()))
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 	marshalOkJson(w, http.StatusOK, chirp)
 }
 
+// createChirpRequest is the body of a new chirp, the chirp fields and the images to attach.
+type createChirpRequest struct {
+	database.CreateChirpParams
+	MediaIDs []uuid.UUID `json:"media_ids"`
+}
+
 // handlerValidateChirp validates the chirp request body and returns the chirp parameters if valid.
 // If the chirp is invalid, it returns an error response and false.
-// It checks for the length of the chirp body and censors bad words.
-func handlerValidateChirp(w http.ResponseWriter, req *http.Request) (database.CreateChirpParams, bool) {
+// It checks for the length of the chirp body and runs it through the moderation rules:
+// censored words are replaced in the returned body, a rejected chirp is answered with 400,
+// and the returned moderation.Result tells the caller if the chirp must be flagged for review.
+func (c *apiConfig) handlerValidateChirp(w http.ResponseWriter, req *http.Request) (createChirpRequest, moderation.Result, bool) {
 	decoder := json.NewDecoder(req.Body)
-	params := database.CreateChirpParams{}
+	params := createChirpRequest{}
 
 	err := decoder.Decode(&params)
 	if err != nil {
 		marshalError(w, http.StatusInternalServerError, err.Error())
-		return database.CreateChirpParams{}, false
+		return createChirpRequest{}, moderation.Result{}, false
 	}
 
 	if len(params.Body) > 140 {
 		marshalError(w, http.StatusBadRequest, "Chirp is too long")
-		return database.CreateChirpParams{}, false
-
-	} else {
-		// not allowed words
-		badWords := map[string]bool{"kerfuffle": true, "sharbert": true, "fornax": true}
-		uncensuredText := strings.Split(params.Body, " ")
-
-		msg := strings.Builder{}
-
-		if params.Body != "" {
-			for _, word := range uncensuredText {
-				if _, exist := badWords[strings.ToLower(word)]; exist {
-					msg.WriteString("****" + " ")
-				} else {
-					msg.WriteString(word + " ")
-				}
-			}
-			// Update params.Body with censored text and trim trailing space
-			params.Body = strings.TrimSpace(msg.String())
-		}
+		return createChirpRequest{}, moderation.Result{}, false
+	}
 
-		return params, true
+	result := c.moderation.Moderate(params.Body)
+	if result.Rejected {
+		marshalError(w, http.StatusBadRequest, "Chirp contains words that are not allowed")
+		return createChirpRequest{}, moderation.Result{}, false
 	}
+
+	params.Body = strings.TrimSpace(result.Text)
+
+	return params, result, true
 }
 
 // handlerDeleteChirp deletes a chirp from the database.
@@ -153,37 +299,197 @@ func handlerValidateChirp(w http.ResponseWriter, req *http.Request) (database.Cr
 // If the user is authorized, it deletes the chirp and returns a 204 No Content response.
 // If the user is not authorized, it returns a 403 Forbidden response.
 // If the chirp does not exist, it returns a 404 Not Found response.
-// It uses the database.DeleteChirpParams struct to validate the chirp parameters.
+// Authors can delete their chirps hidden by a moderator too.
+// Rechirps of the deleted chirp are removed with it, while quote chirps are kept
+// as tombstones: their kind stays "quote" but quote_of becomes null.
+// The open reports of the chirp and of its rechirps are resolved as "deleted",
+// and its images are deleted with their files.
 // The function is part of the apiConfig struct which contains the database connection.
 // It is registered as a handler for the "/chirps/{chirpID}" endpoint with the DELETE method.
 func (c *apiConfig) handlerDeleteChirp(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
-	userID, err := getUserIDFromValidateJWT(c, w, req)
+	userID := userIDFromContext(req.Context())
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	chirp, ok := c.getAuthorChirp(w, req, chirpID, userID, "You can only delete your own chirps")
+	if !ok {
+		return
+	}
+
+	tx, err := c.sqlDB.BeginTx(req.Context(), nil)
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := c.db.WithTx(tx)
+
+	// Reports are resolved before deleting the chirp, which sets their chirp_id to null
+	if _, err := qtx.ResolveDeletedChirpReports(req.Context(), uuid.NullUUID{UUID: chirp.ID, Valid: true}); err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	mediaKeys, err := deleteChirpMedia(req.Context(), qtx, chirp.ID)
 	if err != nil {
-		marshalError(w, http.StatusUnauthorized, err.Error())
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	err = qtx.DeleteChirp(req.Context(), database.DeleteChirpParams{
+		UserID: uuid.NullUUID{UUID: userID, Valid: true},
+		ID:     chirp.ID,
+	})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	if err := tx.Commit(); err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.deleteMediaFiles(mediaKeys...)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getAuthorChirp returns the chirp to be edited or deleted by its author, hidden or not.
+// It writes 403 with the forbidden message if the chirp is visible but not the user's,
+// and 404 if it does not exist or is hidden.
+func (c *apiConfig) getAuthorChirp(w http.ResponseWriter, req *http.Request, chirpID, userID uuid.UUID, forbidden string) (database.Chirp, bool) {
+	chirp, err := c.db.GetAuthorChirp(req.Context(), database.GetAuthorChirpParams{
+		ID:     chirpID,
+		UserID: uuid.NullUUID{UUID: userID, Valid: true},
+	})
+	if err == nil {
+		return chirp, true
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return database.Chirp{}, false
+	}
+
+	if _, err := c.db.GetChirp(req.Context(), chirpID); err == nil {
+		marshalError(w, http.StatusForbidden, forbidden)
+		return database.Chirp{}, false
+	}
+	marshalError(w, http.StatusNotFound, "Chirp not found")
+	return database.Chirp{}, false
+}
+
+// handlerUpdateChirp edits the body of an existing chirp.
+// Only the author of the chirp can edit it, other users get a 403 Forbidden response.
+// Authors can edit their chirps hidden by a moderator too.
+// The new body goes through the same length and moderation rules as handlerCreateChirp.
+// The previous body is stored in the chirp_revisions table in the same statement
+// that updates the chirp, so every edit leaves a revision behind.
+// Hashtags and mentions are extracted again from the new body.
+// It is registered as a handler for the "/chirps/{chirpID}" endpoint with the PUT and PATCH methods.
+func (c *apiConfig) handlerUpdateChirp(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	userID := userIDFromContext(req.Context())
+
 	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
 	if err != nil {
 		marshalError(w, http.StatusBadRequest, "Invalid chirp ID")
 		return
 	}
 
-	chirp, err := c.db.GetChirp(req.Context(), chirpID)
+	chirp, ok := c.getAuthorChirp(w, req, chirpID, userID, "You can only edit your own chirps")
+	if !ok {
+		return
+	}
+
+	if chirp.Kind == "rechirp" {
+		marshalError(w, http.StatusBadRequest, "Rechirps cannot be edited")
+		return
+	}
+
+	chirpValidated, moderationResult, isValid := c.handlerValidateChirp(w, req)
+	if !isValid {
+		return
+	}
+	if chirp.Kind == "quote" && chirpValidated.Body == "" {
+		marshalError(w, http.StatusBadRequest, "A quote chirp must have a body")
+		return
+	}
+
+	tx, err := c.sqlDB.BeginTx(req.Context(), nil)
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := c.db.WithTx(tx)
+
+	updatedChirp, err := qtx.UpdateChirp(req.Context(), database.UpdateChirpParams{
+		ID:     chirp.ID,
+		UserID: uuid.NullUUID{UUID: userID, Valid: true},
+		Body:   chirpValidated.Body,
+	})
 	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := indexChirpEntities(req.Context(), qtx, updatedChirp); err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := flagChirp(req.Context(), qtx, updatedChirp.ID, moderationResult); err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	chirpResp, err := c.newChirpResponse(req.Context(), updatedChirp, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	marshalOkJson(w, http.StatusOK, chirpResp)
+}
+
+// handlerGetChirpRevisions returns the previous bodies of a chirp, oldest first.
+// Each revision has the body, when it was written (created_at) and when it was replaced by an edit (replaced_at).
+// It is registered as a handler for the "/chirps/{chirpID}/revisions" endpoint with the GET method.
+func (c *apiConfig) handlerGetChirpRevisions(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	if _, err := c.db.GetChirp(req.Context(), chirpID); err != nil {
 		marshalError(w, http.StatusNotFound, "Chirp not found")
 		return
 	}
 
-	if userID == chirp.UserID.UUID {
-		c.db.DeleteChirp(req.Context(), database.DeleteChirpParams{
-			UserID: uuid.NullUUID{UUID: userID, Valid: true},
-			ID:     chirp.ID,
-		})
-		w.WriteHeader(http.StatusNoContent)
-	} else {
-		w.WriteHeader(http.StatusForbidden)
+	dbRevisions, err := c.db.GetChirpRevisions(req.Context(), chirpID)
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	revisions := []chirpRevisionResponse{}
+	for _, dbRevision := range dbRevisions {
+		revisions = append(revisions, newChirpRevisionResponse(dbRevision))
 	}
 
+	marshalOkJson(w, http.StatusOK, revisions)
 }
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/federicoReghini/Chirpy/internal/auth"
+	"github.com/federicoReghini/Chirpy/internal/database"
+	"github.com/federicoReghini/Chirpy/internal/lockout"
+	"github.com/federicoReghini/Chirpy/internal/mailer"
+	"github.com/google/uuid"
+)
+
+// emailVerificationTokenDuration is how long a verification link can be used.
+const emailVerificationTokenDuration = 24 * time.Hour
+
+// resendVerificationPolicy limits the verification emails a user can ask for:
+// the third resend locks the user for 10 minutes, doubling with each further resend.
+var resendVerificationPolicy = lockout.Policy{
+	Allowed:     3,
+	BaseLock:    10 * time.Minute,
+	MaxLock:     6 * time.Hour,
+	ForgetAfter: 24 * time.Hour,
+}
+
+// sendVerificationEmail sends a verification link for the email of the user.
+// The links sent before stop working.
+func (c *apiConfig) sendVerificationEmail(ctx context.Context, userID uuid.UUID, email string) error {
+	token, err := auth.MakeToken()
+	if err != nil {
+		return err
+	}
+
+	if err := c.db.DeleteEmailVerificationTokens(ctx, userID); err != nil {
+		return err
+	}
+
+	err = c.db.CreateEmailVerificationToken(ctx, database.CreateEmailVerificationTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    userID,
+		Email:     email,
+		ExpiresAt: time.Now().Add(emailVerificationTokenDuration),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := c.baseURL + "/api/verify-email?token=" + url.QueryEscape(token)
+	return c.mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Verify your Chirpy email",
+		Body: fmt.Sprintf("Welcome to Chirpy!\n\nOpen this link to verify your email and start chirping:\n%s\n\n"+
+			"The link expires in %d hours. If you did not sign up, ignore this email.\n",
+			link, int(emailVerificationTokenDuration.Hours())),
+	})
+}
+
+// handlerVerifyEmail verifies the email of a user with the token of a verification link.
+// A token only verifies the email it was sent to. Opening the link again until it expires
+// responds the same, so a mail scanner or a browser prefetching the link does not burn it.
+// The token is marked used and the email verified in one transaction.
+// It is registered as a handler for the "/api/verify-email" endpoint with the GET method,
+// the token being in the "token" query parameter.
+func (c *apiConfig) handlerVerifyEmail(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	token := req.URL.Query().Get("token")
+	if token == "" {
+		marshalError(w, http.StatusBadRequest, "Missing token")
+		return
+	}
+
+	tx, err := c.sqlDB.BeginTx(req.Context(), nil)
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := c.db.WithTx(tx)
+
+	verificationToken, err := qtx.UseEmailVerificationToken(req.Context(), auth.HashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		marshalError(w, http.StatusBadRequest, "Invalid or expired verification link")
+		return
+	}
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	verified, err := qtx.MarkUserEmailVerified(req.Context(), database.MarkUserEmailVerifiedParams{
+		ID:    verificationToken.UserID,
+		Email: verificationToken.Email,
+	})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if verified == 0 {
+		// The email changed since the link was sent
+		marshalError(w, http.StatusBadRequest, "Invalid or expired verification link")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerResendVerificationEmail sends a new verification link to the authenticated user.
+// It returns 409 if the email is already verified, and 429 with a Retry-After header
+// if the user asked for too many links (see resendVerificationPolicy).
+// It is registered as a handler for the "/api/verify-email/resend" endpoint with the POST method.
+func (c *apiConfig) handlerResendVerificationEmail(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	user, err := c.db.GetUserByID(req.Context(), userIDFromContext(req.Context()))
+	if err != nil {
+		marshalError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if user.EmailVerifiedAt.Valid {
+		marshalError(w, http.StatusConflict, "Email already verified")
+		return
+	}
+
+	key := user.ID.String()
+	if wait := c.verificationResendLimit.Attempt(key); wait > 0 {
+		marshalTooManyRequests(w, wait, "Too many verification emails, try again later")
+		return
+	}
+
+	if err := c.sendVerificationEmail(req.Context(), user.ID, user.Email); err != nil {
+		c.verificationResendLimit.Release(key)
+		log.Printf("Error sending verification email to user %s: %s", user.ID, err)
+		marshalError(w, http.StatusInternalServerError, "Could not send the verification email")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// middlewareRequireVerifiedEmail works like middlewareAuth and also requires
+// the user to have verified their email.
+// It responds 403 Forbidden when the email is not verified.
+func (c *apiConfig) middlewareRequireVerifiedEmail(next http.HandlerFunc) http.HandlerFunc {
+	return c.middlewareAuth(func(w http.ResponseWriter, req *http.Request) {
+		user, err := c.db.GetUserByID(req.Context(), userIDFromContext(req.Context()))
+		if err != nil {
+			marshalError(w, http.StatusUnauthorized, "Invalid or missing token")
+			return
+		}
+
+		if !user.EmailVerifiedAt.Valid {
+			marshalError(w, http.StatusForbidden, "Email not verified")
+			return
+		}
+
+		next(w, req)
+	})
+}
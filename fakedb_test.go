@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+
+	"github.com/federicoReghini/Chirpy/internal/database"
+)
+
+// fakeDB answers the queries of the database package by their sqlc name, so the handlers
+// can be tested without a PostgreSQL server. A query without an answer fails the request.
+type fakeDB struct {
+	mu      sync.Mutex
+	answers map[string]fakeAnswer
+}
+
+// fakeAnswer returns the rows of a query, each a row of driver values in the order of its columns.
+type fakeAnswer func(args []driver.NamedValue) [][]driver.Value
+
+// newFakeDB returns the database handle and the queries of a fake database.
+func newFakeDB() (*fakeDB, *sql.DB, *database.Queries) {
+	f := &fakeDB{answers: map[string]fakeAnswer{}}
+	db := sql.OpenDB(f)
+	return f, db, database.New(db)
+}
+
+// answer sets the rows returned by the query name, or the execution of an :exec query when nil.
+func (f *fakeDB) answer(name string, answer fakeAnswer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.answers[name] = answer
+}
+
+var queryNameRegexp = regexp.MustCompile(`-- name: (\w+)`)
+
+func (f *fakeDB) run(query string, args []driver.NamedValue) ([][]driver.Value, error) {
+	name := query
+	if m := queryNameRegexp.FindStringSubmatch(query); m != nil {
+		name = m[1]
+	}
+
+	f.mu.Lock()
+	answer, ok := f.answers[name]
+	f.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("fake database: unexpected query %s", name)
+	}
+	if answer == nil {
+		return nil, nil
+	}
+	return answer(args), nil
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return fakeDriver{f} }
+
+type fakeDriver struct{ f *fakeDB }
+
+func (d fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{d.f}, nil }
+
+type fakeConn struct{ f *fakeDB }
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("fake database: prepared statements are not supported")
+}
+func (c fakeConn) Close() error              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+func (c fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := c.f.run(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{rows: rows}, nil
+}
+
+func (c fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	rows, err := c.f.run(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(len(rows)), nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return nil
+	}
+	columns := make([]string, len(r.rows[0]))
+	for i := range columns {
+		columns[i] = fmt.Sprintf("column%d", i)
+	}
+	return columns
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/federicoReghini/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type followResponse struct {
+	UserID     uuid.UUID `json:"user_id"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+// handlerFollowUser makes the authenticated user follow the user in the path.
+// Following a user that is already followed is a no-op.
+// It returns 204 No Content on success, 400 if users try to follow themselves
+// and 404 if the user to follow does not exist.
+// It is registered as a handler for the "/users/{userID}/follow" endpoint with the POST method.
+func (c *apiConfig) handlerFollowUser(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	userID := userIDFromContext(req.Context())
+
+	followeeID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if followeeID == userID {
+		marshalError(w, http.StatusBadRequest, "You cannot follow yourself")
+		return
+	}
+
+	if _, err := c.db.GetUserByID(req.Context(), followeeID); err != nil {
+		marshalError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	err = c.db.FollowUser(req.Context(), database.FollowUserParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerUnfollowUser makes the authenticated user stop following the user in the path.
+// Unfollowing a user that is not followed is a no-op.
+// It is registered as a handler for the "/users/{userID}/follow" endpoint with the DELETE method.
+func (c *apiConfig) handlerUnfollowUser(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	userID := userIDFromContext(req.Context())
+
+	followeeID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	err = c.db.UnfollowUser(req.Context(), database.UnfollowUserParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerGetFollowers lists the users following the user in the path, most recent first.
+// It is registered as a handler for the "/users/{userID}/followers" endpoint with the GET method.
+func (c *apiConfig) handlerGetFollowers(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if _, err := c.db.GetUserByID(req.Context(), userID); err != nil {
+		marshalError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	follows, err := c.db.GetFollowers(req.Context(), userID)
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	followers := []followResponse{}
+	for _, follow := range follows {
+		followers = append(followers, followResponse{
+			UserID:     follow.FollowerID,
+			FollowedAt: follow.CreatedAt,
+		})
+	}
+
+	marshalOkJson(w, http.StatusOK, followers)
+}
+
+// handlerGetFollowing lists the users followed by the user in the path, most recent first.
+// It is registered as a handler for the "/users/{userID}/following" endpoint with the GET method.
+func (c *apiConfig) handlerGetFollowing(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if _, err := c.db.GetUserByID(req.Context(), userID); err != nil {
+		marshalError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	follows, err := c.db.GetFollowing(req.Context(), userID)
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	following := []followResponse{}
+	for _, follow := range follows {
+		following = append(following, followResponse{
+			UserID:     follow.FolloweeID,
+			FollowedAt: follow.CreatedAt,
+		})
+	}
+
+	marshalOkJson(w, http.StatusOK, following)
+}
+
+// handlerGetTimeline returns the home timeline of the authenticated user:
+// the chirps of the users they follow, newest first.
+// It accepts the same limit and cursor query parameters as handlerGetChips
+// and responds with the same paginated envelope.
+// It is registered as a handler for the "/timeline" endpoint with the GET method.
+func (c *apiConfig) handlerGetTimeline(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	userID := userIDFromContext(req.Context())
+
+	query := req.URL.Query()
+
+	limit, err := parsePageLimit(query)
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cursor, err := parseChirpCursor(query)
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	cursorCreatedAt, cursorID := cursor.queryParams()
+
+	dbChirps, err := c.db.GetTimeline(req.Context(), database.GetTimelineParams{
+		FollowerID:      userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageLimit:       limit + 1,
+	})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	page, err := c.newChirpsPage(req.Context(), dbChirps, limit, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	marshalOkJson(w, http.StatusOK, page)
+}
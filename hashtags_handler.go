@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/federicoReghini/Chirpy/internal/chirptext"
+	"github.com/federicoReghini/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// indexChirpEntities extracts the hashtags and mentions from the body of a chirp
+// and links them to it. Links from a previous body are removed first, so it is
+// also used after an edit. Mentions of usernames without an account are ignored.
+// It should run in the same transaction that creates or updates the chirp.
+func indexChirpEntities(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	if err := q.DeleteChirpHashtags(ctx, chirp.ID); err != nil {
+		return err
+	}
+	if err := q.DeleteChirpMentions(ctx, chirp.ID); err != nil {
+		return err
+	}
+
+	for _, tag := range chirptext.Hashtags(chirp.Body) {
+		hashtag, err := q.UpsertHashtag(ctx, tag)
+		if err != nil {
+			return err
+		}
+
+		err = q.AddChirpHashtag(ctx, database.AddChirpHashtagParams{
+			ChirpID:   chirp.ID,
+			HashtagID: hashtag.ID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	mentions := chirptext.Mentions(chirp.Body)
+	if len(mentions) == 0 {
+		return nil
+	}
+
+	userIDs, err := q.GetUserIDsByUsernames(ctx, mentions)
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		err := q.AddChirpMention(ctx, database.AddChirpMentionParams{
+			ChirpID: chirp.ID,
+			UserID:  userID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// handlerGetHashtagChirps returns the chirps tagged with the hashtag in the path, newest first.
+// The tag is case-insensitive and can be given with or without the leading '#'.
+// It accepts the same limit and cursor query parameters as handlerGetChips
+// and responds with the same paginated envelope.
+// It is registered as a handler for the "/hashtags/{tag}/chirps" endpoint with the GET method.
+func (c *apiConfig) handlerGetHashtagChirps(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	tag := strings.ToLower(strings.TrimPrefix(req.PathValue("tag"), "#"))
+	if tag == "" {
+		marshalError(w, http.StatusBadRequest, "Invalid hashtag")
+		return
+	}
+
+	query := req.URL.Query()
+
+	limit, err := parsePageLimit(query)
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cursor, err := parseChirpCursor(query)
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	cursorCreatedAt, cursorID := cursor.queryParams()
+
+	dbChirps, err := c.db.GetChirpsByHashtag(req.Context(), database.GetChirpsByHashtagParams{
+		Tag:             tag,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageLimit:       limit + 1,
+	})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	page, err := c.newChirpsPage(req.Context(), dbChirps, limit, optionalUserIDFromContext(req.Context()))
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	marshalOkJson(w, http.StatusOK, page)
+}
+
+// handlerGetUserMentions returns the chirps mentioning the user in the path, newest first.
+// It accepts the same limit and cursor query parameters as handlerGetChips
+// and responds with the same paginated envelope.
+// It is registered as a handler for the "/users/{userID}/mentions" endpoint with the GET method.
+func (c *apiConfig) handlerGetUserMentions(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if _, err := c.db.GetUserByID(req.Context(), userID); err != nil {
+		marshalError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	query := req.URL.Query()
+
+	limit, err := parsePageLimit(query)
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cursor, err := parseChirpCursor(query)
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	cursorCreatedAt, cursorID := cursor.queryParams()
+
+	dbChirps, err := c.db.GetChirpsMentioningUser(req.Context(), database.GetChirpsMentioningUserParams{
+		UserID:          userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageLimit:       limit + 1,
+	})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	page, err := c.newChirpsPage(req.Context(), dbChirps, limit, optionalUserIDFromContext(req.Context()))
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	marshalOkJson(w, http.StatusOK, page)
+}
@@ -2,11 +2,13 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -34,59 +36,134 @@ func CheckPasswordHash(password, hash string) error {
 	return nil
 }
 
-// MakeJWT generates a JWT token for the given user ID with the specified expiration time.
-// It returns the token as a string or an error if the token could not be created.
-// The tokenSecret is used to sign the JWT.
-// The expiresIn parameter specifies the duration after which the token will expire.
-// The userID is the unique identifier for the user for whom the token is being created.
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	claims := jwt.RegisteredClaims{
-		// A usual scenario is to set the expiration time relative to the current time
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		Issuer:    "chirpy",
-		Subject:   userID.String(),
+// dummyHash is a hash of a random password with the cost of HashPassword.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte(rand.Text()), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
 	}
+	return hash
+})
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+// CheckDummyPasswordHash takes as long as CheckPasswordHash and always fails.
+// It is used when the user does not exist, so the response time does not reveal it.
+func CheckDummyPasswordHash(password string) error {
+	if err := bcrypt.CompareHashAndPassword(dummyHash(), []byte(password)); err != nil {
+		return err
+	}
+	return bcrypt.ErrMismatchedHashAndPassword
+}
 
-	signedToken, err := token.SignedString([]byte(tokenSecret))
-	if err != nil {
+// Roles a user can have. Each role has the permissions of the roles before it.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
 
-		return "", err
-	}
+var roleRanks = map[string]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
 
-	return signedToken, nil
+// ValidRole reports whether role is one of the known roles.
+func ValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
 }
 
-// ValidateJWT checks the validity of a JWT token.
-// It returns the user ID if the token is valid or an error if the token is invalid
-// The tokenString is the JWT token to validate.
-// The tokenSecret is used to verify the signature of the JWT.
-// If the token is valid, it returns the user ID as a uuid.UUID.
-// If the token is invalid, it returns an error.
-// The function does not check the expiration of the token; it only verifies the signature.
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(tokenSecret), nil
+// HasRole reports whether a user with the given role has the permissions of the required role.
+// An unknown role has no permissions.
+func HasRole(role, required string) bool {
+	rank, ok := roleRanks[role]
+	return ok && rank >= roleRanks[required]
+}
 
-	})
+// Claims are the claims of the JWTs minted by MakeJWT.
+// The role lets the server authorize a request without querying the user.
+// The session is the login the token was issued from.
+type Claims struct {
+	Role      string `json:"role"`
+	SessionID string `json:"sid,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// UserID returns the ID of the user the token was issued to.
+// It is only meaningful for claims returned by ParseJWT, which checks the subject.
+func (c *Claims) UserID() uuid.UUID {
+	userID, _ := uuid.Parse(c.Subject)
+	return userID
+}
+
+// SessionUUID returns the ID of the session the token was issued from, if any.
+func (c *Claims) SessionUUID() uuid.NullUUID {
+	sessionID, err := uuid.Parse(c.SessionID)
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: sessionID, Valid: true}
+}
+
+// Issuer is the "iss" claim of the JWTs minted by Chirpy.
+const Issuer = "chirpy"
+
+// MakeJWT generates a JWT token for the given user ID with the specified expiration time.
+// It returns the token as a string or an error if the token could not be created.
+// The role of the user is carried in the "role" claim.
+// The sessionID is carried in the "sid" claim, it is left out when it is uuid.Nil.
+// The audience is the "aud" claim, the services the token is meant for. It is left out when empty.
+// The token is signed with the signing key of the set, whose ID is in the "kid" header.
+// The expiresIn parameter specifies the duration after which the token will expire.
+// The userID is the unique identifier for the user for whom the token is being created.
+func (ks *KeySet) MakeJWT(userID uuid.UUID, role string, sessionID uuid.UUID, audience string, expiresIn time.Duration) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		Role: role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			// A usual scenario is to set the expiration time relative to the current time
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    Issuer,
+			Subject:   userID.String(),
+		},
 	}
 
-	claims, ok := token.Claims.(*jwt.RegisteredClaims)
+	if sessionID != uuid.Nil {
+		claims.SessionID = sessionID.String()
+	}
 
-	if !ok || !token.Valid {
-		return uuid.Nil, jwt.ErrInvalidKey
+	if audience != "" {
+		claims.Audience = jwt.ClaimStrings{audience}
 	}
 
-	userID, err := uuid.Parse(claims.Subject)
+	return ks.Sign(claims)
+}
+
+// MakeJWT generates a JWT token signed with HS256 using tokenSecret, without audience.
+// See KeySet.MakeJWT.
+func MakeJWT(userID uuid.UUID, role string, sessionID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	return NewHMACKeySet(tokenSecret).MakeJWT(userID, role, sessionID, "", expiresIn)
+}
+
+// ParseJWT checks a HS256 JWT token signed with tokenSecret and returns its claims.
+// The token must be issued by Chirpy and not be expired, with no leeway.
+// Its audience is not checked. See Validator.Validate for the errors.
+func ParseJWT(tokenString, tokenSecret string) (*Claims, error) {
+	validator := &Validator{keys: NewHMACKeySet(tokenSecret)}
+	return validator.Validate(tokenString)
+}
+
+// ValidateJWT checks a HS256 JWT token signed with tokenSecret, like ParseJWT,
+// and returns the ID of the user it was issued to.
+// Use ParseJWT to also read the role of the user.
+func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	claims, err := ParseJWT(tokenString, tokenSecret)
 	if err != nil {
 		return uuid.Nil, err
 	}
 
-	return userID, nil
+	return claims.UserID(), nil
 }
 
 // GetBearerToken extracts the Bearer token from the Authorization header.
@@ -102,6 +179,12 @@ func GetBearerToken(headers http.Header) (string, error) {
 }
 
 func MakeRefreshToken() (string, error) {
+	return MakeToken()
+}
+
+// MakeToken returns a random 256-bit token, hex-encoded.
+// It is used for the refresh tokens and the tokens of the links sent by email.
+func MakeToken() (string, error) {
 	randomBytes := make([]byte, 32)
 	_, err := rand.Read(randomBytes)
 	if err != nil {
@@ -111,6 +194,14 @@ func MakeRefreshToken() (string, error) {
 	return hex.EncodeToString(randomBytes), nil
 }
 
+// HashToken returns the hex-encoded SHA-256 hash of a token made by MakeToken.
+// Only the hash is stored, so the tokens in the database cannot be used as credentials.
+// The tokens are random, so a fast hash without salt is enough.
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
 func GetAPIKey(headers http.Header) (string, error) {
 	token, err := getTokenFromAuthorizationHeader("ApiKey ", headers)
 	if err != nil {
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestHashPassword(t *testing.T) {
@@ -47,12 +48,27 @@ func TestCheckPasswordHash(t *testing.T) {
 	}
 }
 
+func TestCheckDummyPasswordHash(t *testing.T) {
+	if err := CheckDummyPasswordHash("testpassword123"); err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	// The dummy hash costs as much as a real one, so both checks take as long
+	cost, err := bcrypt.Cost(dummyHash())
+	if err != nil {
+		t.Fatalf("Expected a valid hash, got %v", err)
+	}
+	if cost != bcrypt.DefaultCost {
+		t.Fatalf("Expected cost %d, got %d", bcrypt.DefaultCost, cost)
+	}
+}
+
 func TestMakeJWT(t *testing.T) {
 	userID := uuid.New()
 	tokenSecret := "test-secret"
 	expiresIn := time.Hour
 
-	token, err := MakeJWT(userID, tokenSecret, expiresIn)
+	token, err := MakeJWT(userID, RoleUser, uuid.Nil, tokenSecret, expiresIn)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -68,7 +84,7 @@ func TestValidateJWT_ValidToken(t *testing.T) {
 	expiresIn := time.Hour
 
 	// Create a valid token
-	token, err := MakeJWT(userID, tokenSecret, expiresIn)
+	token, err := MakeJWT(userID, RoleUser, uuid.Nil, tokenSecret, expiresIn)
 	if err != nil {
 		t.Fatalf("Failed to create token: %v", err)
 	}
@@ -84,13 +100,65 @@ func TestValidateJWT_ValidToken(t *testing.T) {
 	}
 }
 
+func TestParseJWT_Claims(t *testing.T) {
+	userID := uuid.New()
+	tokenSecret := "test-secret"
+
+	sessionID := uuid.New()
+
+	token, err := MakeJWT(userID, RoleModerator, sessionID, tokenSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+
+	claims, err := ParseJWT(token, tokenSecret)
+	if err != nil {
+		t.Fatalf("Expected no error for valid token, got %v", err)
+	}
+
+	if claims.UserID() != userID {
+		t.Fatalf("Expected user ID %v, got %v", userID, claims.UserID())
+	}
+
+	if claims.Role != RoleModerator {
+		t.Fatalf("Expected role %q, got %q", RoleModerator, claims.Role)
+	}
+
+	if got := claims.SessionUUID(); !got.Valid || got.UUID != sessionID {
+		t.Fatalf("Expected session ID %v, got %v", sessionID, got)
+	}
+}
+
+func TestHasRole(t *testing.T) {
+	tests := []struct {
+		role     string
+		required string
+		want     bool
+	}{
+		{RoleUser, RoleUser, true},
+		{RoleUser, RoleModerator, false},
+		{RoleModerator, RoleModerator, true},
+		{RoleModerator, RoleAdmin, false},
+		{RoleAdmin, RoleModerator, true},
+		{RoleAdmin, RoleAdmin, true},
+		{"", RoleUser, false},
+		{"superuser", RoleUser, false},
+	}
+
+	for _, tt := range tests {
+		if got := HasRole(tt.role, tt.required); got != tt.want {
+			t.Errorf("HasRole(%q, %q) = %v, want %v", tt.role, tt.required, got, tt.want)
+		}
+	}
+}
+
 func TestValidateJWT_ExpiredToken(t *testing.T) {
 	userID := uuid.New()
 	tokenSecret := "test-secret"
 	expiresIn := -time.Hour // Expired token
 
 	// Create an expired token
-	token, err := MakeJWT(userID, tokenSecret, expiresIn)
+	token, err := MakeJWT(userID, RoleUser, uuid.Nil, tokenSecret, expiresIn)
 	if err != nil {
 		t.Fatalf("Failed to create token: %v", err)
 	}
@@ -109,7 +177,7 @@ func TestValidateJWT_WrongSecret(t *testing.T) {
 	expiresIn := time.Hour
 
 	// Create a token with one secret
-	token, err := MakeJWT(userID, tokenSecret, expiresIn)
+	token, err := MakeJWT(userID, RoleUser, uuid.Nil, tokenSecret, expiresIn)
 	if err != nil {
 		t.Fatalf("Failed to create token: %v", err)
 	}
@@ -148,7 +216,7 @@ func TestMakeJWT_ZeroExpiration(t *testing.T) {
 	tokenSecret := "test-secret"
 	expiresIn := time.Duration(0) // Immediate expiration
 
-	token, err := MakeJWT(userID, tokenSecret, expiresIn)
+	token, err := MakeJWT(userID, RoleUser, uuid.Nil, tokenSecret, expiresIn)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -159,6 +227,18 @@ func TestMakeJWT_ZeroExpiration(t *testing.T) {
 	}
 }
 
+func TestHashToken(t *testing.T) {
+	// echo -n "abc" | sha256sum
+	want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got := HashToken("abc"); got != want {
+		t.Fatalf("Expected hash %s, got %s", want, got)
+	}
+
+	if HashToken("abc") == HashToken("abd") {
+		t.Fatal("Expected different tokens to have different hashes")
+	}
+}
+
 func TestGetBearerToken_ValidToken(t *testing.T) {
 
 	headers := http.Header{}
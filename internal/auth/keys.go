@@ -0,0 +1,273 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key is a key used to sign or verify JWTs, identified by its key ID (the "kid" header).
+// The signing method follows from the type of the key: RS256 for RSA keys,
+// EdDSA for Ed25519 keys and HS256 for shared secrets.
+// A key without a private part can only verify tokens.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	// signKey is nil for verification-only keys
+	signKey   any
+	verifyKey any
+}
+
+// NewHMACKey returns a HS256 key for the shared secret.
+// HMAC keys are never published in the JWKS.
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{
+		ID:        id,
+		Method:    jwt.SigningMethodHS256,
+		signKey:   secret,
+		verifyKey: secret,
+	}
+}
+
+// NewPrivateKey returns a key that can sign and verify, from an RSA or Ed25519 private key.
+func NewPrivateKey(id string, privateKey crypto.Signer) (*Key, error) {
+	switch k := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return &Key{ID: id, Method: jwt.SigningMethodRS256, signKey: k, verifyKey: &k.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: id, Method: jwt.SigningMethodEdDSA, signKey: k, verifyKey: k.Public()}, nil
+	default:
+		return nil, fmt.Errorf("key %s: unsupported private key type %T", id, privateKey)
+	}
+}
+
+// NewPublicKey returns a verification-only key from an RSA or Ed25519 public key.
+// It is used to keep accepting the tokens signed by a retired key.
+func NewPublicKey(id string, publicKey crypto.PublicKey) (*Key, error) {
+	switch k := publicKey.(type) {
+	case *rsa.PublicKey:
+		return &Key{ID: id, Method: jwt.SigningMethodRS256, verifyKey: k}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: id, Method: jwt.SigningMethodEdDSA, verifyKey: k}, nil
+	default:
+		return nil, fmt.Errorf("key %s: unsupported public key type %T", id, publicKey)
+	}
+}
+
+// ParseKeyPEM parses a PEM encoded key.
+// It accepts PKCS #8 private keys ("PRIVATE KEY"), PKCS #1 RSA private keys ("RSA PRIVATE KEY")
+// and PKIX public keys ("PUBLIC KEY").
+func ParseKeyPEM(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %s: no PEM block found", id)
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+		signer, ok := privateKey.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("key %s: unsupported private key type %T", id, privateKey)
+		}
+		return NewPrivateKey(id, signer)
+	case "RSA PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+		return NewPrivateKey(id, privateKey)
+	case "PUBLIC KEY":
+		publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+		return NewPublicKey(id, publicKey)
+	default:
+		return nil, fmt.Errorf("key %s: unsupported PEM block %q", id, block.Type)
+	}
+}
+
+// KeySet holds the keys used for JWTs: the one new tokens are signed with,
+// and all the keys tokens are verified with, selected by the "kid" header.
+//
+// To rotate keys, add the new key to the set and make it the signing key,
+// keeping the previous key (its public part is enough) until the tokens
+// it signed have expired, then remove it.
+type KeySet struct {
+	signingKey *Key
+	keys       map[string]*Key
+}
+
+// NewKeySet returns a set of the given keys, signing with the key whose ID is signingKeyID.
+// The signing key must have a private part, and key IDs must be unique.
+func NewKeySet(signingKeyID string, keys ...*Key) (*KeySet, error) {
+	ks := &KeySet{keys: map[string]*Key{}}
+	for _, key := range keys {
+		if _, ok := ks.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key ID %q", key.ID)
+		}
+		ks.keys[key.ID] = key
+	}
+
+	signingKey, ok := ks.keys[signingKeyID]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q not found", signingKeyID)
+	}
+	if signingKey.signKey == nil {
+		return nil, fmt.Errorf("signing key %q has no private key", signingKeyID)
+	}
+	ks.signingKey = signingKey
+
+	return ks, nil
+}
+
+// NewHMACKeySet returns a set with a single HS256 key for the shared secret.
+// The key has an empty ID, so its tokens have no "kid" header.
+func NewHMACKeySet(secret string) *KeySet {
+	key := NewHMACKey("", []byte(secret))
+	return &KeySet{
+		signingKey: key,
+		keys:       map[string]*Key{key.ID: key},
+	}
+}
+
+// LoadKeySet loads every "<kid>.pem" file of dir as a key (see ParseKeyPEM)
+// and signs with the key whose ID is signingKeyID.
+func LoadKeySet(dir, signingKeyID string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*Key, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := ParseKeyPEM(strings.TrimSuffix(filepath.Base(path), ".pem"), data)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return NewKeySet(signingKeyID, keys...)
+}
+
+// Sign signs the claims with the signing key and sets the "kid" header.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.signingKey.Method, claims)
+	if ks.signingKey.ID != "" {
+		token.Header["kid"] = ks.signingKey.ID
+	}
+
+	return token.SignedString(ks.signingKey.signKey)
+}
+
+// algorithms returns the signing algorithms of the keys of the set.
+func (ks *KeySet) algorithms() []string {
+	seen := map[string]bool{}
+	algs := []string{}
+	for _, key := range ks.keys {
+		if alg := key.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			algs = append(algs, alg)
+		}
+	}
+	sort.Strings(algs)
+	return algs
+}
+
+// keyfunc returns the key to verify a token with, selected by its "kid" header.
+// A token without "kid" is verified with the signing key.
+// The algorithm of the token must be the one of the key, so a token cannot, for example,
+// be signed with HS256 using a public RSA key as the secret.
+func (ks *KeySet) keyfunc(token *jwt.Token) (any, error) {
+	key := ks.signingKey
+	if kid, ok := token.Header["kid"]; ok {
+		kidString, ok := kid.(string)
+		if !ok {
+			return nil, errors.New("invalid kid header")
+		}
+		if key, ok = ks.keys[kidString]; !ok {
+			return nil, fmt.Errorf("unknown key ID %q", kidString)
+		}
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+
+	return key.verifyKey, nil
+}
+
+// JWK is a public key in the JSON Web Key format (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519 keys
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the set, sorted by key ID.
+// HMAC keys are secrets and are left out.
+func (ks *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range ks.keys {
+		jwk := JWK{
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: key.Method.Alg(),
+		}
+
+		switch k := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(k)
+		default:
+			continue
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	sort.Slice(jwks.Keys, func(i, j int) bool {
+		return jwks.Keys[i].KeyID < jwks.Keys[j].KeyID
+	})
+
+	return jwks
+}
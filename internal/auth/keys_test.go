@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func newRSAKey(t *testing.T, id string) *Key {
+	t.Helper()
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	key, err := NewPrivateKey(id, privateKey)
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	return key
+}
+
+func newEd25519Key(t *testing.T, id string) *Key {
+	t.Helper()
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+	key, err := NewPrivateKey(id, privateKey)
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	return key
+}
+
+func TestKeySet_RoundTrip(t *testing.T) {
+	for _, key := range []*Key{newRSAKey(t, "rsa-1"), newEd25519Key(t, "ed-1")} {
+		t.Run(key.Method.Alg(), func(t *testing.T) {
+			ks, err := NewKeySet(key.ID, key)
+			if err != nil {
+				t.Fatalf("Failed to create key set: %v", err)
+			}
+
+			userID := uuid.New()
+			tokenString, err := ks.MakeJWT(userID, RoleUser, uuid.Nil, testAudience, time.Hour)
+			if err != nil {
+				t.Fatalf("Failed to make JWT: %v", err)
+			}
+
+			token, _, err := jwt.NewParser().ParseUnverified(tokenString, &Claims{})
+			if err != nil {
+				t.Fatalf("Failed to parse JWT: %v", err)
+			}
+			if token.Header["kid"] != key.ID {
+				t.Fatalf("Expected kid %q, got %v", key.ID, token.Header["kid"])
+			}
+			if token.Method.Alg() != key.Method.Alg() {
+				t.Fatalf("Expected alg %s, got %s", key.Method.Alg(), token.Method.Alg())
+			}
+
+			claims, err := newTestValidator(t, ks).Validate(tokenString)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if claims.UserID() != userID {
+				t.Fatalf("Expected user ID %v, got %v", userID, claims.UserID())
+			}
+		})
+	}
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	oldKey := newRSAKey(t, "2024-01")
+	newKey := newEd25519Key(t, "2024-06")
+
+	oldKeySet, err := NewKeySet(oldKey.ID, oldKey)
+	if err != nil {
+		t.Fatalf("Failed to create key set: %v", err)
+	}
+	oldToken, err := oldKeySet.MakeJWT(uuid.New(), RoleUser, uuid.Nil, testAudience, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to make JWT: %v", err)
+	}
+
+	// The old key is retired: only its public part is kept, for verification
+	retiredKey, err := NewPublicKey(oldKey.ID, oldKey.verifyKey)
+	if err != nil {
+		t.Fatalf("Failed to create public key: %v", err)
+	}
+	rotatedKeySet, err := NewKeySet(newKey.ID, newKey, retiredKey)
+	if err != nil {
+		t.Fatalf("Failed to create key set: %v", err)
+	}
+
+	if _, err := newTestValidator(t, rotatedKeySet).Validate(oldToken); err != nil {
+		t.Fatalf("Expected token of the retired key to stay valid, got %v", err)
+	}
+
+	newToken, err := rotatedKeySet.MakeJWT(uuid.New(), RoleUser, uuid.Nil, testAudience, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to make JWT: %v", err)
+	}
+	if _, err := newTestValidator(t, oldKeySet).Validate(newToken); err == nil {
+		t.Fatal("Expected error for token of a key not in the set")
+	}
+
+	if _, err := NewKeySet(retiredKey.ID, retiredKey); err == nil {
+		t.Fatal("Expected error for a signing key without private key")
+	}
+}
+
+func TestKeySet_AlgorithmConfusion(t *testing.T) {
+	key := newRSAKey(t, "rsa-1")
+	ks, err := NewKeySet(key.ID, key)
+	if err != nil {
+		t.Fatalf("Failed to create key set: %v", err)
+	}
+
+	// A HS256 token using the public key as the secret must be rejected
+	publicDER, err := x509.MarshalPKIXPublicKey(key.verifyKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal public key: %v", err)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   uuid.New().String(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	token.Header["kid"] = key.ID
+	tokenString, err := token.SignedString(publicDER)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+
+	if _, err := newTestValidator(t, ks).Validate(tokenString); err == nil {
+		t.Fatal("Expected error for a token with another algorithm than its key")
+	}
+
+	// The HS256 helpers reject asymmetric tokens too
+	rsaToken, err := ks.MakeJWT(uuid.New(), RoleUser, uuid.Nil, testAudience, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to make JWT: %v", err)
+	}
+	if _, err := ParseJWT(rsaToken, "secret"); err == nil {
+		t.Fatal("Expected error for a RS256 token with ParseJWT")
+	}
+}
+
+func TestKeySet_UnknownKeyID(t *testing.T) {
+	key := newEd25519Key(t, "ed-1")
+	ks, err := NewKeySet(key.ID, key)
+	if err != nil {
+		t.Fatalf("Failed to create key set: %v", err)
+	}
+
+	other := newEd25519Key(t, "ed-2")
+	otherKeySet, err := NewKeySet(other.ID, other)
+	if err != nil {
+		t.Fatalf("Failed to create key set: %v", err)
+	}
+	tokenString, err := otherKeySet.MakeJWT(uuid.New(), RoleUser, uuid.Nil, testAudience, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to make JWT: %v", err)
+	}
+
+	if _, err := newTestValidator(t, ks).Validate(tokenString); err == nil {
+		t.Fatal("Expected error for unknown kid")
+	}
+}
+
+func TestKeySet_JWKS(t *testing.T) {
+	rsaKey := newRSAKey(t, "b-rsa")
+	edKey := newEd25519Key(t, "a-ed")
+	ks, err := NewKeySet(rsaKey.ID, rsaKey, edKey, NewHMACKey("hmac", []byte("secret")))
+	if err != nil {
+		t.Fatalf("Failed to create key set: %v", err)
+	}
+
+	jwks := ks.JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("Expected 2 public keys, got %d", len(jwks.Keys))
+	}
+
+	edJWK, rsaJWK := jwks.Keys[0], jwks.Keys[1]
+	if edJWK.KeyID != "a-ed" || edJWK.KeyType != "OKP" || edJWK.Curve != "Ed25519" || edJWK.Algorithm != "EdDSA" || edJWK.X == "" {
+		t.Fatalf("Unexpected Ed25519 JWK: %+v", edJWK)
+	}
+	if rsaJWK.KeyID != "b-rsa" || rsaJWK.KeyType != "RSA" || rsaJWK.Algorithm != "RS256" || rsaJWK.N == "" || rsaJWK.E != "AQAB" {
+		t.Fatalf("Unexpected RSA JWK: %+v", rsaJWK)
+	}
+
+	if len(NewHMACKeySet("secret").JWKS().Keys) != 0 {
+		t.Fatal("Expected no public keys for a HMAC key set")
+	}
+}
+
+func TestLoadKeySet(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	writePEM(t, filepath.Join(dir, "old.pem"), "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	writePEM(t, filepath.Join(dir, "current.pem"), "PRIVATE KEY", der)
+
+	der, err = x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	writePEM(t, filepath.Join(dir, "retired.pem"), "PUBLIC KEY", der)
+
+	ks, err := LoadKeySet(dir, "current")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if ks.signingKey.Method.Alg() != "EdDSA" {
+		t.Fatalf("Expected EdDSA signing key, got %s", ks.signingKey.Method.Alg())
+	}
+	if len(ks.JWKS().Keys) != 3 {
+		t.Fatalf("Expected 3 public keys, got %d", len(ks.JWKS().Keys))
+	}
+
+	if _, err := LoadKeySet(dir, "retired"); err == nil {
+		t.Fatal("Expected error for a public signing key")
+	}
+	if _, err := LoadKeySet(dir, "missing"); err == nil {
+		t.Fatal("Expected error for a missing signing key")
+	}
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// MaxLeeway is the largest clock skew a Validator can tolerate.
+const MaxLeeway = 2 * time.Minute
+
+// Errors returned by Validator.Validate, to be checked with errors.Is.
+var (
+	ErrTokenMalformed        = errors.New("malformed token")
+	ErrTokenSignatureInvalid = errors.New("invalid token signature")
+	ErrTokenExpired          = errors.New("token expired")
+	ErrTokenNotValidYet      = errors.New("token not valid yet")
+	ErrTokenInvalidIssuer    = errors.New("invalid token issuer")
+	ErrTokenInvalidAudience  = errors.New("invalid token audience")
+	ErrTokenInvalidClaims    = errors.New("invalid token claims")
+)
+
+// ValidatorConfig configures the checks of a Validator.
+type ValidatorConfig struct {
+	// Audience must be one of the "aud" claims of the tokens
+	Audience string
+	// Leeway is the clock skew tolerated on the "exp", "nbf" and "iat" claims, at most MaxLeeway
+	Leeway time.Duration
+}
+
+// Validator checks the JWTs minted by Chirpy.
+// A token is valid when:
+//   - its algorithm is the one of the key of the set named by its "kid" header
+//   - its signature is valid
+//   - its issuer is Issuer and its audience contains the configured audience
+//   - it has an expiration time that is not past, and was not issued in the future,
+//     give or take the leeway
+//   - its subject is a user ID
+type Validator struct {
+	keys   *KeySet
+	config ValidatorConfig
+}
+
+// NewValidator returns a validator of the tokens signed by the keys of the set.
+// The audience is required and the leeway must be between 0 and MaxLeeway.
+func NewValidator(keys *KeySet, config ValidatorConfig) (*Validator, error) {
+	if config.Audience == "" {
+		return nil, errors.New("JWT audience is required")
+	}
+	if config.Leeway < 0 || config.Leeway > MaxLeeway {
+		return nil, fmt.Errorf("JWT leeway must be between 0 and %s, got %s", MaxLeeway, config.Leeway)
+	}
+
+	return &Validator{keys: keys, config: config}, nil
+}
+
+// Validate checks the token and returns its claims.
+// The error wraps one of the ErrToken errors, telling why the token was rejected.
+func (v *Validator) Validate(tokenString string) (*Claims, error) {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(v.keys.algorithms()),
+		jwt.WithIssuer(Issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(v.config.Leeway),
+	}
+	if v.config.Audience != "" {
+		options = append(options, jwt.WithAudience(v.config.Audience))
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, v.keys.keyfunc, options...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", v.tokenError(token, err), err)
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, ErrTokenInvalidClaims
+	}
+
+	if _, err := uuid.Parse(claims.Subject); err != nil {
+		return nil, fmt.Errorf("%w: invalid subject: %w", ErrTokenInvalidClaims, err)
+	}
+
+	return claims, nil
+}
+
+// tokenError maps an error of the jwt package to the ErrToken error for it.
+// A token that cannot be verified, because its key is unknown or its algorithm
+// is not the one of its key, has an invalid signature.
+// A token without audience has an invalid audience.
+func (v *Validator) tokenError(token *jwt.Token, err error) error {
+	switch {
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return ErrTokenMalformed
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return ErrTokenSignatureInvalid
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return ErrTokenExpired
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return ErrTokenNotValidYet
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return ErrTokenInvalidIssuer
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return ErrTokenInvalidAudience
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing) && v.config.Audience != "" && missingAudience(token):
+		return ErrTokenInvalidAudience
+	default:
+		return ErrTokenInvalidClaims
+	}
+}
+
+// missingAudience reports whether the token has no "aud" claim.
+func missingAudience(token *jwt.Token) bool {
+	if token == nil {
+		return false
+	}
+	claims, ok := token.Claims.(*Claims)
+	return ok && len(claims.Audience) == 0
+}
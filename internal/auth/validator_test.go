@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const testAudience = "chirpy-test"
+
+func newTestValidator(t *testing.T, ks *KeySet) *Validator {
+	t.Helper()
+	validator, err := NewValidator(ks, ValidatorConfig{Audience: testAudience, Leeway: 30 * time.Second})
+	if err != nil {
+		t.Fatalf("Failed to create validator: %v", err)
+	}
+	return validator
+}
+
+func signTestClaims(t *testing.T, ks *KeySet, claims jwt.RegisteredClaims) string {
+	t.Helper()
+	token, err := ks.Sign(Claims{Role: RoleUser, RegisteredClaims: claims})
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return token
+}
+
+func TestNewValidator_Config(t *testing.T) {
+	ks := NewHMACKeySet("secret")
+
+	if _, err := NewValidator(ks, ValidatorConfig{Leeway: time.Second}); err == nil {
+		t.Fatal("Expected error for missing audience")
+	}
+	if _, err := NewValidator(ks, ValidatorConfig{Audience: testAudience, Leeway: MaxLeeway + time.Second}); err == nil {
+		t.Fatal("Expected error for too large leeway")
+	}
+	if _, err := NewValidator(ks, ValidatorConfig{Audience: testAudience, Leeway: -time.Second}); err == nil {
+		t.Fatal("Expected error for negative leeway")
+	}
+}
+
+func TestValidator_Validate(t *testing.T) {
+	ks := NewHMACKeySet("secret")
+	validator := newTestValidator(t, ks)
+	now := time.Now()
+	valid := func() jwt.RegisteredClaims {
+		return jwt.RegisteredClaims{
+			Issuer:    Issuer,
+			Subject:   uuid.New().String(),
+			Audience:  jwt.ClaimStrings{testAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		token   func() string
+		wantErr error
+	}{
+		{
+			name:  "valid",
+			token: func() string { return signTestClaims(t, ks, valid()) },
+		},
+		{
+			name: "expired within leeway",
+			token: func() string {
+				claims := valid()
+				claims.ExpiresAt = jwt.NewNumericDate(now.Add(-10 * time.Second))
+				return signTestClaims(t, ks, claims)
+			},
+		},
+		{
+			name: "expired",
+			token: func() string {
+				claims := valid()
+				claims.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute))
+				return signTestClaims(t, ks, claims)
+			},
+			wantErr: ErrTokenExpired,
+		},
+		{
+			name: "no expiration",
+			token: func() string {
+				claims := valid()
+				claims.ExpiresAt = nil
+				return signTestClaims(t, ks, claims)
+			},
+			wantErr: ErrTokenInvalidClaims,
+		},
+		{
+			name: "issued in the future",
+			token: func() string {
+				claims := valid()
+				claims.IssuedAt = jwt.NewNumericDate(now.Add(time.Minute))
+				return signTestClaims(t, ks, claims)
+			},
+			wantErr: ErrTokenNotValidYet,
+		},
+		{
+			name: "wrong issuer",
+			token: func() string {
+				claims := valid()
+				claims.Issuer = "someone-else"
+				return signTestClaims(t, ks, claims)
+			},
+			wantErr: ErrTokenInvalidIssuer,
+		},
+		{
+			name: "wrong audience",
+			token: func() string {
+				claims := valid()
+				claims.Audience = jwt.ClaimStrings{"other-service"}
+				return signTestClaims(t, ks, claims)
+			},
+			wantErr: ErrTokenInvalidAudience,
+		},
+		{
+			name: "no audience",
+			token: func() string {
+				claims := valid()
+				claims.Audience = nil
+				return signTestClaims(t, ks, claims)
+			},
+			wantErr: ErrTokenInvalidAudience,
+		},
+		{
+			name:    "wrong secret",
+			token:   func() string { return signTestClaims(t, NewHMACKeySet("other-secret"), valid()) },
+			wantErr: ErrTokenSignatureInvalid,
+		},
+		{
+			name: "unsigned",
+			token: func() string {
+				token, err := jwt.NewWithClaims(jwt.SigningMethodNone, valid()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+				if err != nil {
+					t.Fatalf("Failed to sign token: %v", err)
+				}
+				return token
+			},
+			wantErr: ErrTokenSignatureInvalid,
+		},
+		{
+			name: "other algorithm",
+			token: func() string {
+				token, err := jwt.NewWithClaims(jwt.SigningMethodHS512, valid()).SignedString([]byte("secret"))
+				if err != nil {
+					t.Fatalf("Failed to sign token: %v", err)
+				}
+				return token
+			},
+			wantErr: ErrTokenSignatureInvalid,
+		},
+		{
+			name: "subject is not a user ID",
+			token: func() string {
+				claims := valid()
+				claims.Subject = "admin"
+				return signTestClaims(t, ks, claims)
+			},
+			wantErr: ErrTokenInvalidClaims,
+		},
+		{
+			name:    "malformed",
+			token:   func() string { return "invalid.token.string" },
+			wantErr: ErrTokenMalformed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := validator.Validate(tt.token())
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestKeySet_MakeJWT_Audience(t *testing.T) {
+	ks := NewHMACKeySet("secret")
+
+	token, err := ks.MakeJWT(uuid.New(), RoleUser, uuid.Nil, testAudience, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to make JWT: %v", err)
+	}
+	claims, err := newTestValidator(t, ks).Validate(token)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if claims.Issuer != Issuer {
+		t.Fatalf("Expected issuer %q, got %q", Issuer, claims.Issuer)
+	}
+
+	// The tokens of MakeJWT have no audience
+	token, err = MakeJWT(uuid.New(), RoleUser, uuid.Nil, "secret", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to make JWT: %v", err)
+	}
+	if _, err := newTestValidator(t, ks).Validate(token); !errors.Is(err, ErrTokenInvalidAudience) {
+		t.Fatalf("Expected error %v, got %v", ErrTokenInvalidAudience, err)
+	}
+}
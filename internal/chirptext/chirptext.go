@@ -0,0 +1,70 @@
+// Package chirptext extracts hashtags and mentions from the body of a chirp.
+package chirptext
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	hashtagRegexp = regexp.MustCompile(`#([\p{L}\p{N}_]+)`)
+	// A mention is the username of the account, 3 to 30 letters, digits or underscores: @bob
+	mentionRegexp = regexp.MustCompile(`@([A-Za-z0-9_]{3,30})`)
+)
+
+// Hashtags returns the hashtags in body, without the leading '#', lowercased and deduplicated.
+// A '#' preceded by a letter, digit or underscore (e.g. "page#section") does not start a hashtag.
+func Hashtags(body string) []string {
+	return extract(hashtagRegexp, body)
+}
+
+// Mentions returns the usernames mentioned in body, without the leading '@', lowercased and deduplicated.
+// An '@' preceded by a letter, digit or underscore does not start a mention,
+// so an email address is not one, and a handle longer than a username is not one either.
+func Mentions(body string) []string {
+	return extract(mentionRegexp, body)
+}
+
+func extract(re *regexp.Regexp, body string) []string {
+	matches := []string{}
+	seen := map[string]bool{}
+
+	for _, loc := range re.FindAllStringSubmatchIndex(body, -1) {
+		if !startsToken(body, loc[0]) || !endsToken(body, loc[1]) {
+			continue
+		}
+
+		match := strings.ToLower(body[loc[2]:loc[3]])
+		if seen[match] {
+			continue
+		}
+		seen[match] = true
+		matches = append(matches, match)
+	}
+
+	return matches
+}
+
+// startsToken reports whether the match at index i is not glued to the previous word.
+func startsToken(body string, i int) bool {
+	if i == 0 {
+		return true
+	}
+	r, _ := utf8.DecodeLastRuneInString(body[:i])
+	return !isWordRune(r)
+}
+
+// endsToken reports whether the match ending at index i is not glued to the next word.
+func endsToken(body string, i int) bool {
+	if i == len(body) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(body[i:])
+	return !isWordRune(r)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
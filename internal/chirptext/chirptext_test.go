@@ -0,0 +1,66 @@
+package chirptext
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHashtags(t *testing.T) {
+	got := Hashtags("Learning #Go and #golang, again #go!")
+	want := []string{"go", "golang"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestHashtags_IgnoresGluedHash(t *testing.T) {
+	got := Hashtags("see page#section and C#")
+
+	if len(got) != 0 {
+		t.Fatalf("Expected no hashtags, got %v", got)
+	}
+}
+
+func TestHashtags_Unicode(t *testing.T) {
+	got := Hashtags("(#café)")
+	want := []string{"café"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestHashtags_Empty(t *testing.T) {
+	got := Hashtags("")
+
+	if got == nil || len(got) != 0 {
+		t.Fatalf("Expected empty slice, got %v", got)
+	}
+}
+
+func TestMentions(t *testing.T) {
+	got := Mentions("Hi @Bob and @alice_42, bye @bob.")
+	want := []string{"bob", "alice_42"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestMentions_IgnoresPlainEmail(t *testing.T) {
+	got := Mentions("write to bob@example.com")
+
+	if len(got) != 0 {
+		t.Fatalf("Expected no mentions, got %v", got)
+	}
+}
+
+func TestMentions_IgnoresInvalidUsernames(t *testing.T) {
+	got := Mentions("@al is too short, @" + strings.Repeat("a", 31) + " too long, @café not ascii")
+
+	if len(got) != 0 {
+		t.Fatalf("Expected no mentions, got %v", got)
+	}
+}
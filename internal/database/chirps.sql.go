@@ -7,29 +7,43 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to, kind, quote_of)
 VALUES (
   gen_random_uuid(),
   NOW(),
   NOW(),
   $1,
-  $2
+  $2,
+  $3,
+  $4,
+  $5
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, kind, rechirp_of, quote_of, body_tsv, hidden_at
 `
 
 type CreateChirpParams struct {
-	Body   string        `json:"body"`
-	UserID uuid.NullUUID `json:"user_id"`
+	Body      string        `json:"body"`
+	UserID    uuid.NullUUID `json:"user_id"`
+	InReplyTo uuid.NullUUID `json:"in_reply_to"`
+	Kind      string        `json:"kind"`
+	QuoteOf   uuid.NullUUID `json:"quote_of"`
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.InReplyTo,
+		arg.Kind,
+		arg.QuoteOf,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,6 +51,51 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyTo,
+		&i.Kind,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.BodyTsv,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
+const createRechirp = `-- name: CreateRechirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, kind, rechirp_of)
+VALUES (
+  gen_random_uuid(),
+  NOW(),
+  NOW(),
+  '',
+  $1,
+  'rechirp',
+  $2
+)
+ON CONFLICT (user_id, rechirp_of) WHERE rechirp_of IS NOT NULL DO NOTHING
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, kind, rechirp_of, quote_of, body_tsv, hidden_at
+`
+
+type CreateRechirpParams struct {
+	UserID    uuid.NullUUID `json:"user_id"`
+	RechirpOf uuid.NullUUID `json:"rechirp_of"`
+}
+
+func (q *Queries) CreateRechirp(ctx context.Context, arg CreateRechirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, createRechirp, arg.UserID, arg.RechirpOf)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.Kind,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.BodyTsv,
+		&i.HiddenAt,
 	)
 	return i, err
 }
@@ -56,9 +115,57 @@ func (q *Queries) DeleteChirp(ctx context.Context, arg DeleteChirpParams) error
 	return err
 }
 
+const deleteRechirp = `-- name: DeleteRechirp :execrows
+DELETE FROM chirps
+  WHERE chirps.user_id = $1 AND chirps.rechirp_of = $2 AND chirps.kind = 'rechirp'
+`
+
+type DeleteRechirpParams struct {
+	UserID    uuid.NullUUID `json:"user_id"`
+	RechirpOf uuid.NullUUID `json:"rechirp_of"`
+}
+
+func (q *Queries) DeleteRechirp(ctx context.Context, arg DeleteRechirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteRechirp, arg.UserID, arg.RechirpOf)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getAuthorChirp = `-- name: GetAuthorChirp :one
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, kind, rechirp_of, quote_of, body_tsv, hidden_at FROM chirps
+WHERE chirps.id = $1 AND chirps.user_id = $2
+`
+
+type GetAuthorChirpParams struct {
+	ID     uuid.UUID     `json:"id"`
+	UserID uuid.NullUUID `json:"user_id"`
+}
+
+// Hidden chirps included, their author can still edit and delete them
+func (q *Queries) GetAuthorChirp(ctx context.Context, arg GetAuthorChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getAuthorChirp, arg.ID, arg.UserID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.Kind,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.BodyTsv,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
 const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE chirps.id = $1
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, kind, rechirp_of, quote_of, body_tsv, hidden_at FROM chirps
+WHERE chirps.id = $1 AND chirps.hidden_at IS NULL
 `
 
 func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -70,19 +177,35 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyTo,
+		&i.Kind,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.BodyTsv,
+		&i.HiddenAt,
 	)
 	return i, err
 }
 
-const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-ORDER BY 
-CASE  WHEN $1 = 'asc' THEN  created_at END ASC,
-CASE  WHEN $1 = 'desc' THEN  created_at END DESC
+const getChirpAncestors = `-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors (id, depth) AS (
+  SELECT reply.in_reply_to, 1
+  FROM chirps AS reply
+  WHERE reply.id = $1 AND reply.in_reply_to IS NOT NULL
+  UNION ALL
+  SELECT chirps.in_reply_to, ancestors.depth + 1
+  FROM chirps
+  JOIN ancestors ON chirps.id = ancestors.id
+  WHERE chirps.in_reply_to IS NOT NULL AND ancestors.depth < 100
+)
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.kind, chirps.rechirp_of, chirps.quote_of, chirps.body_tsv, chirps.hidden_at FROM chirps
+JOIN ancestors ON chirps.id = ancestors.id
+WHERE chirps.hidden_at IS NULL
+ORDER BY ancestors.depth DESC
 `
 
-func (q *Queries) GetChirps(ctx context.Context, dollar_1 interface{}) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps, dollar_1)
+func (q *Queries) GetChirpAncestors(ctx context.Context, id uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpAncestors, id)
 	if err != nil {
 		return nil, err
 	}
@@ -96,6 +219,124 @@ func (q *Queries) GetChirps(ctx context.Context, dollar_1 interface{}) ([]Chirp,
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			&i.Kind,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.BodyTsv,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpDescendants = `-- name: GetChirpDescendants :many
+WITH RECURSIVE descendants (id, depth) AS (
+  SELECT chirps.id, 1
+  FROM chirps
+  WHERE chirps.in_reply_to = $1
+  UNION ALL
+  SELECT chirps.id, descendants.depth + 1
+  FROM chirps
+  JOIN descendants ON chirps.in_reply_to = descendants.id
+  WHERE descendants.depth < 100
+)
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.kind, chirps.rechirp_of, chirps.quote_of, chirps.body_tsv, chirps.hidden_at FROM chirps
+JOIN descendants ON chirps.id = descendants.id
+WHERE chirps.hidden_at IS NULL
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT 1000
+`
+
+func (q *Queries) GetChirpDescendants(ctx context.Context, inReplyTo uuid.NullUUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpDescendants, inReplyTo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.Kind,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.BodyTsv,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpReplies = `-- name: GetChirpReplies :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, kind, rechirp_of, quote_of, body_tsv, hidden_at FROM chirps
+WHERE chirps.in_reply_to = $1
+  AND (
+    $2::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) > ($2::timestamp, $3::uuid)
+  )
+  AND chirps.hidden_at IS NULL
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT $4
+`
+
+type GetChirpRepliesParams struct {
+	ChirpID         uuid.NullUUID `json:"chirp_id"`
+	CursorCreatedAt sql.NullTime  `json:"cursor_created_at"`
+	CursorID        uuid.NullUUID `json:"cursor_id"`
+	PageLimit       int32         `json:"page_limit"`
+}
+
+func (q *Queries) GetChirpReplies(ctx context.Context, arg GetChirpRepliesParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpReplies,
+		arg.ChirpID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.Kind,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.BodyTsv,
+			&i.HiddenAt,
 		); err != nil {
 			return nil, err
 		}
@@ -111,9 +352,9 @@ func (q *Queries) GetChirps(ctx context.Context, dollar_1 interface{}) ([]Chirp,
 }
 
 const getChirpsByUser = `-- name: GetChirpsByUser :many
-SELECT id, created_at, updated_at, body, user_id
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, kind, rechirp_of, quote_of, body_tsv, hidden_at
   FROM chirps 
-  WHERE chirps.user_id = $1
+  WHERE chirps.user_id = $1 AND chirps.hidden_at IS NULL
 `
 
 func (q *Queries) GetChirpsByUser(ctx context.Context, userID uuid.NullUUID) ([]Chirp, error) {
@@ -131,6 +372,209 @@ func (q *Queries) GetChirpsByUser(ctx context.Context, userID uuid.NullUUID) ([]
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			&i.Kind,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.BodyTsv,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsAsc = `-- name: ListChirpsAsc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, kind, rechirp_of, quote_of, body_tsv, hidden_at FROM chirps
+WHERE ($1::uuid IS NULL OR chirps.user_id = $1::uuid)
+  AND (
+    $2::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) > ($2::timestamp, $3::uuid)
+  )
+  AND chirps.hidden_at IS NULL
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT $4
+`
+
+type ListChirpsAscParams struct {
+	AuthorID        uuid.NullUUID `json:"author_id"`
+	CursorCreatedAt sql.NullTime  `json:"cursor_created_at"`
+	CursorID        uuid.NullUUID `json:"cursor_id"`
+	PageLimit       int32         `json:"page_limit"`
+}
+
+func (q *Queries) ListChirpsAsc(ctx context.Context, arg ListChirpsAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsAsc,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.Kind,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.BodyTsv,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsDesc = `-- name: ListChirpsDesc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, kind, rechirp_of, quote_of, body_tsv, hidden_at FROM chirps
+WHERE ($1::uuid IS NULL OR chirps.user_id = $1::uuid)
+  AND (
+    $2::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < ($2::timestamp, $3::uuid)
+  )
+  AND chirps.hidden_at IS NULL
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT $4
+`
+
+type ListChirpsDescParams struct {
+	AuthorID        uuid.NullUUID `json:"author_id"`
+	CursorCreatedAt sql.NullTime  `json:"cursor_created_at"`
+	CursorID        uuid.NullUUID `json:"cursor_id"`
+	PageLimit       int32         `json:"page_limit"`
+}
+
+func (q *Queries) ListChirpsDesc(ctx context.Context, arg ListChirpsDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDesc,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.Kind,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.BodyTsv,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchChirps = `-- name: SearchChirps :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.kind, chirps.rechirp_of, chirps.quote_of, chirps.body_tsv, chirps.hidden_at, ts_rank(chirps.body_tsv, websearch_to_tsquery('english', $1::text)) AS rank
+FROM chirps
+WHERE chirps.body_tsv @@ websearch_to_tsquery('english', $1::text)
+  AND ($2::uuid IS NULL OR chirps.user_id = $2::uuid)
+  AND ($3::timestamp IS NULL OR chirps.created_at >= $3::timestamp)
+  AND ($4::timestamp IS NULL OR chirps.created_at < $4::timestamp)
+  AND chirps.hidden_at IS NULL
+ORDER BY rank DESC, chirps.created_at DESC, chirps.id DESC
+LIMIT $5 OFFSET $6
+`
+
+type SearchChirpsParams struct {
+	Query      string        `json:"query"`
+	AuthorID   uuid.NullUUID `json:"author_id"`
+	Since      sql.NullTime  `json:"since"`
+	Until      sql.NullTime  `json:"until"`
+	PageLimit  int32         `json:"page_limit"`
+	PageOffset int32         `json:"page_offset"`
+}
+
+type SearchChirpsRow struct {
+	ID        uuid.UUID     `json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	Body      string        `json:"body"`
+	UserID    uuid.NullUUID `json:"user_id"`
+	InReplyTo uuid.NullUUID `json:"in_reply_to"`
+	Kind      string        `json:"kind"`
+	RechirpOf uuid.NullUUID `json:"rechirp_of"`
+	QuoteOf   uuid.NullUUID `json:"quote_of"`
+	BodyTsv   interface{}   `json:"body_tsv"`
+	HiddenAt  sql.NullTime  `json:"hidden_at"`
+	Rank      float32       `json:"rank"`
+}
+
+func (q *Queries) SearchChirps(ctx context.Context, arg SearchChirpsParams) ([]SearchChirpsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirps,
+		arg.Query,
+		arg.AuthorID,
+		arg.Since,
+		arg.Until,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchChirpsRow
+	for rows.Next() {
+		var i SearchChirpsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.Kind,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.BodyTsv,
+			&i.HiddenAt,
+			&i.Rank,
 		); err != nil {
 			return nil, err
 		}
@@ -17,22 +17,149 @@ type Chirp struct {
 	UpdatedAt time.Time     `json:"updated_at"`
 	Body      string        `json:"body"`
 	UserID    uuid.NullUUID `json:"user_id"`
+	InReplyTo uuid.NullUUID `json:"in_reply_to"`
+	Kind      string        `json:"kind"`
+	RechirpOf uuid.NullUUID `json:"rechirp_of"`
+	QuoteOf   uuid.NullUUID `json:"quote_of"`
+	BodyTsv   interface{}   `json:"body_tsv"`
+	HiddenAt  sql.NullTime  `json:"hidden_at"`
+}
+
+type ChirpFlag struct {
+	ID           uuid.UUID    `json:"id"`
+	ChirpID      uuid.UUID    `json:"chirp_id"`
+	MatchedWords []string     `json:"matched_words"`
+	CreatedAt    time.Time    `json:"created_at"`
+	ReviewedAt   sql.NullTime `json:"reviewed_at"`
+}
+
+type ChirpHashtag struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	HashtagID uuid.UUID `json:"hashtag_id"`
+}
+
+type ChirpLike struct {
+	UserID    uuid.UUID `json:"user_id"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ChirpMention struct {
+	ChirpID uuid.UUID `json:"chirp_id"`
+	UserID  uuid.UUID `json:"user_id"`
+}
+
+type ChirpRevision struct {
+	ID         uuid.UUID `json:"id"`
+	ChirpID    uuid.UUID `json:"chirp_id"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+type EmailVerificationToken struct {
+	TokenHash string       `json:"-"`
+	UserID    uuid.UUID    `json:"user_id"`
+	Email     string       `json:"email"`
+	CreatedAt time.Time    `json:"created_at"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
+type Follow struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FolloweeID uuid.UUID `json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type Hashtag struct {
+	ID        uuid.UUID `json:"id"`
+	Tag       string    `json:"tag"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Media struct {
+	ID           uuid.UUID     `json:"id"`
+	UserID       uuid.UUID     `json:"user_id"`
+	ContentType  string        `json:"content_type"`
+	SizeBytes    int64         `json:"size_bytes"`
+	Width        int32         `json:"width"`
+	Height       int32         `json:"height"`
+	StorageKey   string        `json:"storage_key"`
+	ThumbnailKey string        `json:"thumbnail_key"`
+	CreatedAt    time.Time     `json:"created_at"`
+	ChirpID      uuid.NullUUID `json:"chirp_id"`
+	Position     int32         `json:"position"`
+}
+
+type ModerationAction struct {
+	ID          uuid.UUID     `json:"id"`
+	ModeratorID uuid.NullUUID `json:"moderator_id"`
+	Action      string        `json:"action"`
+	ChirpID     uuid.UUID     `json:"chirp_id"`
+	ReportID    uuid.NullUUID `json:"report_id"`
+	Note        string        `json:"note"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+type ModerationRule struct {
+	Word      string    `json:"word"`
+	Action    string    `json:"action"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type PasswordResetToken struct {
+	TokenHash string       `json:"-"`
+	UserID    uuid.UUID    `json:"user_id"`
+	CreatedAt time.Time    `json:"created_at"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
 }
 
 type RefreshToken struct {
-	Token     string        `json:"token"`
+	TokenHash string        `json:"-"`
 	CreatedAt time.Time     `json:"created_at"`
 	UpdatedAt time.Time     `json:"updated_at"`
 	UserID    uuid.NullUUID `json:"user_id"`
 	ExpiresAt time.Time     `json:"expires_at"`
 	RevokedAt sql.NullTime  `json:"revoked_at"`
+	FamilyID  uuid.UUID     `json:"family_id"`
+	RotatedAt sql.NullTime  `json:"rotated_at"`
+}
+
+type Report struct {
+	ID         uuid.UUID      `json:"id"`
+	ChirpID    uuid.NullUUID  `json:"chirp_id"`
+	ReporterID uuid.UUID      `json:"reporter_id"`
+	Reason     string         `json:"reason"`
+	Details    string         `json:"details"`
+	CreatedAt  time.Time      `json:"created_at"`
+	ResolvedAt sql.NullTime   `json:"resolved_at"`
+	ResolvedBy uuid.NullUUID  `json:"resolved_by"`
+	Resolution sql.NullString `json:"resolution"`
+}
+
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	UserID     uuid.UUID `json:"user_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	UserAgent  string    `json:"user_agent"`
+	Ip         string    `json:"ip"`
 }
 
 type User struct {
-	ID             uuid.UUID `json:"id"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
-	Email          string    `json:"email"`
-	HashedPassword string    `json:"hashed_password"`
-	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	ID              uuid.UUID    `json:"id"`
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+	Email           string       `json:"email"`
+	HashedPassword  string       `json:"-"`
+	IsChirpyRed     bool         `json:"is_chirpy_red"`
+	Role            string       `json:"role"`
+	EmailVerifiedAt sql.NullTime `json:"email_verified_at"`
+	Username        string       `json:"username"`
+	DisplayName     string       `json:"display_name"`
+	Bio             string       `json:"bio"`
+	AvatarUrl       string       `json:"avatar_url"`
 }
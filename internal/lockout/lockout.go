@@ -0,0 +1,128 @@
+// Package lockout slows down password guessing, and other actions that must not be repeated at will.
+// A Tracker counts the attempts of a key, such as an email or an IP address,
+// and locks the key for a time that doubles with each attempt past the allowed ones.
+// An attempt is counted before it is tried, so concurrent attempts cannot get past the lock,
+// and given back when it succeeds.
+// The counts are kept in memory, so each server instance has its own.
+package lockout
+
+import (
+	"sync"
+	"time"
+)
+
+// Policy sets when and for how long a key is locked.
+type Policy struct {
+	// Allowed is the number of failures that lock the key
+	Allowed int
+	// BaseLock is the lock after the Allowed-th failure, it doubles with each further failure
+	BaseLock time.Duration
+	// MaxLock caps the lock
+	MaxLock time.Duration
+	// ForgetAfter is how long after its last failure a key starts again from zero
+	ForgetAfter time.Duration
+}
+
+// Lock returns how long a key is locked after its failures-th failure.
+func (p Policy) Lock(failures int) time.Duration {
+	if failures < p.Allowed {
+		return 0
+	}
+
+	lock := p.BaseLock
+	for range failures - p.Allowed {
+		if lock >= p.MaxLock {
+			break
+		}
+		lock *= 2
+	}
+	return min(lock, p.MaxLock)
+}
+
+type entry struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// Tracker counts the failures of the keys. It is safe for concurrent use.
+type Tracker struct {
+	policy Policy
+	now    func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]*entry
+	lastPrune time.Time
+}
+
+// NewTracker returns a tracker applying the policy.
+func NewTracker(policy Policy) *Tracker {
+	return &Tracker{
+		policy:  policy,
+		now:     time.Now,
+		entries: map[string]*entry{},
+	}
+}
+
+// Attempt counts an attempt of the key, as a failure until it is given back.
+// It returns how long the key stays locked, 0 if the attempt can be tried now.
+// A refused attempt is not counted.
+func (t *Tracker) Attempt(key string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	t.prune(now)
+
+	e, ok := t.entries[key]
+	if !ok || now.Sub(e.lastFailure) > t.policy.ForgetAfter {
+		e = &entry{}
+		t.entries[key] = e
+	}
+
+	if wait := e.lockedUntil.Sub(now); wait > 0 {
+		return wait
+	}
+
+	e.failures++
+	e.lastFailure = now
+	e.lockedUntil = now.Add(t.policy.Lock(e.failures))
+	return 0
+}
+
+// Release gives back an attempt of the key that succeeded,
+// without forgetting its failures as Reset does.
+func (t *Tracker) Release(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.entries[key]
+	if !ok || e.failures == 0 {
+		return
+	}
+	e.failures--
+	e.lockedUntil = e.lastFailure.Add(t.policy.Lock(e.failures))
+}
+
+// Reset forgets the failures of the key, after a success.
+func (t *Tracker) Reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.entries, key)
+}
+
+// prune removes the keys whose failures are forgotten, at most once per ForgetAfter,
+// so the keys tried once do not stay in memory.
+func (t *Tracker) prune(now time.Time) {
+	if now.Sub(t.lastPrune) < t.policy.ForgetAfter {
+		return
+	}
+	t.lastPrune = now
+
+	for key, e := range t.entries {
+		if now.Sub(e.lastFailure) > t.policy.ForgetAfter && !now.Before(e.lockedUntil) {
+			delete(t.entries, key)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package lockout
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{
+	Allowed:     3,
+	BaseLock:    time.Second,
+	MaxLock:     10 * time.Second,
+	ForgetAfter: time.Minute,
+}
+
+// newTestTracker returns a tracker whose clock only moves with the returned function.
+func newTestTracker() (*Tracker, func(time.Duration)) {
+	tr := NewTracker(testPolicy)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tr.now = func() time.Time { return now }
+	return tr, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestPolicy_Lock(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{5, 4 * time.Second},
+		{6, 8 * time.Second},
+		{7, 10 * time.Second},
+		{1000, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := testPolicy.Lock(tt.failures); got != tt.want {
+			t.Errorf("Lock(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestTracker_Attempt(t *testing.T) {
+	tr, advance := newTestTracker()
+
+	for i := range 3 {
+		if wait := tr.Attempt("gopher@example.com"); wait != 0 {
+			t.Fatalf("Expected attempt %d to be allowed, got %v", i+1, wait)
+		}
+	}
+
+	if wait := tr.Attempt("gopher@example.com"); wait != time.Second {
+		t.Fatalf("Expected to wait 1s, got %v", wait)
+	}
+	if wait := tr.Attempt("other@example.com"); wait != 0 {
+		t.Fatalf("Expected other keys not to be locked, got %v", wait)
+	}
+
+	advance(time.Second)
+	if wait := tr.Attempt("gopher@example.com"); wait != 0 {
+		t.Fatalf("Expected the lock to be over, got %v", wait)
+	}
+	if wait := tr.Attempt("gopher@example.com"); wait != 2*time.Second {
+		t.Fatalf("Expected the lock to double, got %v", wait)
+	}
+}
+
+func TestTracker_Release(t *testing.T) {
+	tr, _ := newTestTracker()
+
+	// Successful attempts never lock the key
+	for range 10 {
+		if wait := tr.Attempt("10.0.0.1"); wait != 0 {
+			t.Fatalf("Expected no lock after successes, got %v", wait)
+		}
+		tr.Release("10.0.0.1")
+	}
+
+	tr.Attempt("10.0.0.1")
+	tr.Attempt("10.0.0.1")
+	tr.Attempt("10.0.0.1")
+	tr.Release("10.0.0.1")
+	if wait := tr.Attempt("10.0.0.1"); wait != 0 {
+		t.Fatalf("Expected the released attempt to unlock the key, got %v", wait)
+	}
+	if wait := tr.Attempt("10.0.0.1"); wait != time.Second {
+		t.Fatalf("Expected the failures to be kept, got %v", wait)
+	}
+}
+
+func TestTracker_Reset(t *testing.T) {
+	tr, _ := newTestTracker()
+
+	for range 5 {
+		tr.Attempt("gopher@example.com")
+	}
+	tr.Reset("gopher@example.com")
+
+	for i := range 3 {
+		if wait := tr.Attempt("gopher@example.com"); wait != 0 {
+			t.Fatalf("Expected the count to start from zero, got %v at attempt %d", wait, i+1)
+		}
+	}
+}
+
+func TestTracker_Forget(t *testing.T) {
+	tr, advance := newTestTracker()
+
+	for range 3 {
+		tr.Attempt("gopher@example.com")
+	}
+	advance(2 * time.Minute)
+
+	if wait := tr.Attempt("gopher@example.com"); wait != 0 {
+		t.Fatalf("Expected old failures to be forgotten, got %v", wait)
+	}
+	if e := tr.entries["gopher@example.com"]; e.failures != 1 {
+		t.Fatalf("Expected the count to start from zero, got %d failures", e.failures)
+	}
+}
+
+func TestTracker_Prune(t *testing.T) {
+	tr, advance := newTestTracker()
+
+	tr.Attempt("a")
+	tr.Attempt("b")
+	advance(2 * time.Minute)
+	tr.Attempt("c")
+
+	if len(tr.entries) != 1 {
+		t.Fatalf("Expected the forgotten keys to be pruned, got %d keys", len(tr.entries))
+	}
+}
+
+// TestTracker_ConcurrentAttempts fires a burst of attempts at once:
+// no more than Allowed of them may get through before the lock.
+func TestTracker_ConcurrentAttempts(t *testing.T) {
+	tr := NewTracker(testPolicy)
+
+	var wg sync.WaitGroup
+	var allowed atomic.Int32
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if tr.Attempt("gopher@example.com") == 0 {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := allowed.Load(); got != int32(testPolicy.Allowed) {
+		t.Fatalf("Expected %d attempts to get through, got %d", testPolicy.Allowed, got)
+	}
+}
@@ -0,0 +1,85 @@
+// Package mailer sends the transactional emails of Chirpy, such as the email verification links.
+// The Mailer interface lets the delivery be swapped: LogMailer and FileMailer are meant for
+// local development, they print or save the emails instead of sending them.
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	// Body is plain text
+	Body string
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// ValidAddress reports whether s is a bare email address, like "gopher@example.com".
+// Addresses with a display name or angle brackets are rejected.
+func ValidAddress(s string) bool {
+	address, err := mail.ParseAddress(s)
+	return err == nil && address.Name == "" && address.Address == s
+}
+
+// LogMailer writes the messages to a logger instead of sending them.
+type LogMailer struct {
+	Logger *log.Logger
+}
+
+// NewLogMailer returns a mailer writing to the standard logger.
+func NewLogMailer() *LogMailer {
+	return &LogMailer{Logger: log.Default()}
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	m.Logger.Printf("Email to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileMailer saves each message as an .eml file in Dir instead of sending it.
+type FileMailer struct {
+	Dir  string
+	From string
+	// count keeps the names of the files unique within the same nanosecond
+	count atomic.Int64
+}
+
+// NewFileMailer returns a mailer saving the messages in dir, which is created if needed.
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileMailer{Dir: dir, From: from}, nil
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("%d-%d.eml", now.UnixNano(), m.count.Add(1))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.From)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	return os.WriteFile(filepath.Join(m.Dir, name), []byte(b.String()), 0o600)
+}
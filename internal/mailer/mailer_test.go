@@ -0,0 +1,82 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{"gopher@example.com", true},
+		{"first.last+tag@sub.example.org", true},
+		{"", false},
+		{"not-an-email", false},
+		{"@example.com", false},
+		{"Gopher <gopher@example.com>", false},
+		{"<gopher@example.com>", false},
+		{" gopher@example.com", false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidAddress(tt.address); got != tt.want {
+			t.Errorf("ValidAddress(%q) = %v, want %v", tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestLogMailer_Send(t *testing.T) {
+	var buf bytes.Buffer
+	m := &LogMailer{Logger: log.New(&buf, "", 0)}
+
+	err := m.Send(context.Background(), Message{To: "gopher@example.com", Subject: "Hello", Body: "Some body"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for _, want := range []string{"gopher@example.com", "Hello", "Some body"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("Expected log to contain %q, got %q", want, buf.String())
+		}
+	}
+}
+
+func TestFileMailer_Send(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m, err := NewFileMailer(dir, "chirpy@example.com")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for range 2 {
+		err := m.Send(context.Background(), Message{To: "gopher@example.com", Subject: "Hello", Body: "Line 1\nLine 2"})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 files, got %d", len(files))
+	}
+
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, want := range []string{"From: chirpy@example.com\r\n", "To: gopher@example.com\r\n", "Subject: Hello\r\n", "\r\n\r\nLine 1\r\nLine 2"} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("Expected file to contain %q, got %q", want, string(data))
+		}
+	}
+}
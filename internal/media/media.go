@@ -0,0 +1,231 @@
+// Package media checks the uploaded images and makes their thumbnails.
+// Only JPEG, PNG and GIF images are accepted, they are recognized by their content
+// and not by the file name or the Content-Type sent by the client.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+)
+
+// Limits of the images
+const (
+	// MaxDimension is the largest width or height of an image
+	MaxDimension = 8000
+	// MaxPixels is the largest number of pixels of an image, about 64 MB once decoded
+	MaxPixels = 16 << 20
+	// ThumbnailSize is the largest width or height of a thumbnail
+	ThumbnailSize = 320
+	// thumbnailSamples is the number of pixels read per row and per column
+	// of the box each thumbnail pixel covers, larger boxes are subsampled
+	thumbnailSamples = 4
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported image type, must be JPEG, PNG or GIF")
+	ErrInvalidImage    = errors.New("invalid image")
+	ErrTooLarge        = errors.New("image dimensions are too large")
+	// ErrFileTooLarge is returned by ReadLimited when the file is over the limit
+	ErrFileTooLarge = errors.New("file is too large")
+)
+
+// Content types of the accepted images
+const (
+	TypeJPEG = "image/jpeg"
+	TypePNG  = "image/png"
+	TypeGIF  = "image/gif"
+)
+
+// Image is an uploaded image and its thumbnail.
+type Image struct {
+	ContentType string
+	Width       int
+	Height      int
+	Thumbnail   []byte
+	// ThumbnailType is TypeJPEG for JPEG images and TypePNG for the others
+	ThumbnailType string
+}
+
+// Extension returns the file extension of a content type, with the dot.
+func Extension(contentType string) string {
+	switch contentType {
+	case TypeJPEG:
+		return ".jpg"
+	case TypePNG:
+		return ".png"
+	case TypeGIF:
+		return ".gif"
+	}
+	return ""
+}
+
+// DetectType returns the content type of the image in data, or ErrUnsupportedType.
+func DetectType(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	if Extension(contentType) == "" {
+		return "", ErrUnsupportedType
+	}
+	return contentType, nil
+}
+
+// Process checks that data is a valid image of an accepted type,
+// at most MaxDimension pixels wide and high and MaxPixels in all, and makes its thumbnail.
+// The dimensions are checked before decoding the image, so a small file
+// claiming huge dimensions is rejected without allocating its pixels.
+func Process(data []byte) (Image, error) {
+	contentType, err := DetectType(data)
+	if err != nil {
+		return Image{}, err
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || "image/"+format != contentType {
+		return Image{}, ErrInvalidImage
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return Image{}, ErrInvalidImage
+	}
+	if config.Width > MaxDimension || config.Height > MaxDimension || config.Width*config.Height > MaxPixels {
+		return Image{}, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, ErrInvalidImage
+	}
+
+	result := Image{
+		ContentType: contentType,
+		Width:       config.Width,
+		Height:      config.Height,
+	}
+
+	var thumbnail bytes.Buffer
+	thumb := Thumbnail(img, ThumbnailSize)
+	if contentType == TypeJPEG {
+		result.ThumbnailType = TypeJPEG
+		err = jpeg.Encode(&thumbnail, thumb, &jpeg.Options{Quality: 80})
+	} else {
+		result.ThumbnailType = TypePNG
+		err = png.Encode(&thumbnail, thumb)
+	}
+	if err != nil {
+		return Image{}, err
+	}
+	result.Thumbnail = thumbnail.Bytes()
+
+	return result, nil
+}
+
+// Thumbnail scales img down to fit in a size x size square, keeping its aspect ratio.
+// Each pixel of the thumbnail is the average of the pixels it covers, at most
+// thumbnailSamples x thumbnailSamples of them evenly spread, so the cost depends
+// on the size of the thumbnail and not of the image.
+// Images already small enough are only copied.
+func Thumbnail(img image.Image, size int) *image.NRGBA {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	tw, th := w, h
+	if w > size || h > size {
+		if w >= h {
+			tw, th = size, max(1, h*size/w)
+		} else {
+			tw, th = max(1, w*size/h), size
+		}
+	}
+
+	pixel := pixelReader(img)
+	thumb := image.NewNRGBA(image.Rect(0, 0, tw, th))
+	for ty := range th {
+		y0 := bounds.Min.Y + ty*h/th
+		y1 := max(y0+1, bounds.Min.Y+(ty+1)*h/th)
+		stepY := max(1, (y1-y0)/thumbnailSamples)
+		for tx := range tw {
+			x0 := bounds.Min.X + tx*w/tw
+			x1 := max(x0+1, bounds.Min.X+(tx+1)*w/tw)
+			stepX := max(1, (x1-x0)/thumbnailSamples)
+
+			var r, g, b, a, n uint64
+			for y := y0; y < y1; y += stepY {
+				for x := x0; x < x1; x += stepX {
+					pr, pg, pb, pa := pixel(x, y)
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+
+			// The averages are premultiplied by alpha, NRGBA is not
+			i := thumb.PixOffset(tx, ty)
+			if a == 0 {
+				continue
+			}
+			thumb.Pix[i+0] = uint8(r * 0xff / a)
+			thumb.Pix[i+1] = uint8(g * 0xff / a)
+			thumb.Pix[i+2] = uint8(b * 0xff / a)
+			thumb.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+
+	return thumb
+}
+
+// pixelReader returns a function reading the alpha-premultiplied color of a pixel of img,
+// like img.At(x, y).RGBA(). The types the decoders return are read from their pixels
+// directly, without allocating a color for each one.
+func pixelReader(img image.Image) func(x, y int) (r, g, b, a uint32) {
+	switch img := img.(type) {
+	case *image.YCbCr:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			yi, ci := img.YOffset(x, y), img.COffset(x, y)
+			return color.YCbCr{Y: img.Y[yi], Cb: img.Cb[ci], Cr: img.Cr[ci]}.RGBA()
+		}
+	case *image.RGBA:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			i := img.PixOffset(x, y)
+			return color.RGBA{R: img.Pix[i], G: img.Pix[i+1], B: img.Pix[i+2], A: img.Pix[i+3]}.RGBA()
+		}
+	case *image.NRGBA:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			i := img.PixOffset(x, y)
+			return color.NRGBA{R: img.Pix[i], G: img.Pix[i+1], B: img.Pix[i+2], A: img.Pix[i+3]}.RGBA()
+		}
+	case *image.Paletted:
+		// The palette is converted once, not for every pixel
+		palette := make([][4]uint32, len(img.Palette))
+		for i, c := range img.Palette {
+			r, g, b, a := c.RGBA()
+			palette[i] = [4]uint32{r, g, b, a}
+		}
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			i := int(img.Pix[img.PixOffset(x, y)])
+			if i >= len(palette) {
+				return 0, 0, 0, 0
+			}
+			c := palette[i]
+			return c[0], c[1], c[2], c[3]
+		}
+	}
+	return func(x, y int) (uint32, uint32, uint32, uint32) {
+		return img.At(x, y).RGBA()
+	}
+}
+
+// ReadLimited reads r up to limit bytes, and returns ErrFileTooLarge if r is longer.
+func ReadLimited(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, ErrFileTooLarge
+	}
+	return data, nil
+}
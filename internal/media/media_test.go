@@ -0,0 +1,186 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func testImage(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	return img
+}
+
+func encode(t *testing.T, contentType string, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var err error
+	switch contentType {
+	case TypeJPEG:
+		err = jpeg.Encode(&buf, img, nil)
+	case TypePNG:
+		err = png.Encode(&buf, img)
+	case TypeGIF:
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatalf("Error encoding %s: %v", contentType, err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		contentType   string
+		thumbnailType string
+	}{
+		{TypeJPEG, TypeJPEG},
+		{TypePNG, TypePNG},
+		{TypeGIF, TypePNG},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			img, err := Process(encode(t, tt.contentType, testImage(640, 480)))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if img.ContentType != tt.contentType || img.Width != 640 || img.Height != 480 {
+				t.Fatalf("Expected %s 640x480, got %s %dx%d", tt.contentType, img.ContentType, img.Width, img.Height)
+			}
+			if img.ThumbnailType != tt.thumbnailType {
+				t.Fatalf("Expected thumbnail type %s, got %s", tt.thumbnailType, img.ThumbnailType)
+			}
+
+			thumb, format, err := image.Decode(bytes.NewReader(img.Thumbnail))
+			if err != nil {
+				t.Fatalf("Expected a valid thumbnail, got %v", err)
+			}
+			if "image/"+format != tt.thumbnailType {
+				t.Fatalf("Expected thumbnail format %s, got %s", tt.thumbnailType, format)
+			}
+			if b := thumb.Bounds(); b.Dx() != ThumbnailSize || b.Dy() != 240 {
+				t.Fatalf("Expected a %dx240 thumbnail, got %dx%d", ThumbnailSize, b.Dx(), b.Dy())
+			}
+		})
+	}
+}
+
+func TestProcess_Rejected(t *testing.T) {
+	pngData := encode(t, TypePNG, testImage(10, 10))
+
+	// A PNG header claiming dimensions over the limit, the pixels are never decoded
+	huge := bytes.Clone(pngData)
+	binary.BigEndian.PutUint32(huge[16:], 10000) // width of the IHDR chunk
+	binary.BigEndian.PutUint32(huge[29:], crc32.ChecksumIEEE(huge[12:29]))
+
+	// Each dimension is under MaxDimension, but not the number of pixels
+	manyPixels := bytes.Clone(pngData)
+	binary.BigEndian.PutUint32(manyPixels[16:], 5000)
+	binary.BigEndian.PutUint32(manyPixels[20:], 5000)
+	binary.BigEndian.PutUint32(manyPixels[29:], crc32.ChecksumIEEE(manyPixels[12:29]))
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"text", []byte("hello, this is not an image"), ErrUnsupportedType},
+		{"html", []byte("<html><script>alert(1)</script></html>"), ErrUnsupportedType},
+		{"empty", nil, ErrUnsupportedType},
+		{"truncated", pngData[:40], ErrInvalidImage},
+		{"too large", huge, ErrTooLarge},
+		{"too many pixels", manyPixels, ErrTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Process(tt.data); !errors.Is(err, tt.want) {
+				t.Fatalf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	tests := []struct {
+		w, h, wantW, wantH int
+	}{
+		{640, 480, 320, 240},
+		{480, 640, 240, 320},
+		{100, 50, 100, 50},
+		{5000, 1, 320, 1},
+	}
+
+	for _, tt := range tests {
+		thumb := Thumbnail(testImage(tt.w, tt.h), 320)
+		if b := thumb.Bounds(); b.Dx() != tt.wantW || b.Dy() != tt.wantH {
+			t.Errorf("Thumbnail of %dx%d: expected %dx%d, got %dx%d", tt.w, tt.h, tt.wantW, tt.wantH, b.Dx(), b.Dy())
+		}
+	}
+
+	// A uniform image keeps its color
+	img := image.NewNRGBA(image.Rect(0, 0, 50, 50))
+	for i := 0; i < len(img.Pix); i += 4 {
+		copy(img.Pix[i:], []uint8{10, 20, 30, 255})
+	}
+	if got := Thumbnail(img, 10).NRGBAAt(3, 3); got != (color.NRGBA{10, 20, 30, 255}) {
+		t.Errorf("Expected the color to be kept, got %v", got)
+	}
+}
+
+func TestPixelReader(t *testing.T) {
+	src := testImage(16, 16)
+	src.Set(3, 4, color.NRGBA{R: 200, G: 100, B: 50, A: 128})
+
+	rgba := image.NewRGBA(src.Bounds())
+	ycbcr := image.NewYCbCr(src.Bounds(), image.YCbCrSubsampleRatio420)
+	paletted := image.NewPaletted(src.Bounds(), color.Palette{color.Black, color.White, color.NRGBA{R: 200, A: 128}})
+	for y := range 16 {
+		for x := range 16 {
+			rgba.Set(x, y, src.At(x, y))
+			paletted.SetColorIndex(x, y, uint8((x+y)%3))
+			yy, cb, cr := color.RGBToYCbCr(uint8(x*16), uint8(y*16), 200)
+			ycbcr.Y[ycbcr.YOffset(x, y)] = yy
+			ycbcr.Cb[ycbcr.COffset(x, y)] = cb
+			ycbcr.Cr[ycbcr.COffset(x, y)] = cr
+		}
+	}
+
+	for _, img := range []image.Image{src, rgba, ycbcr, paletted} {
+		pixel := pixelReader(img)
+		for y := range 16 {
+			for x := range 16 {
+				r, g, b, a := pixel(x, y)
+				wr, wg, wb, wa := img.At(x, y).RGBA()
+				if r != wr || g != wg || b != wb || a != wa {
+					t.Fatalf("%T at (%d, %d): expected %v, got %v", img, x, y, []uint32{wr, wg, wb, wa}, []uint32{r, g, b, a})
+				}
+			}
+		}
+	}
+}
+
+func TestReadLimited(t *testing.T) {
+	data, err := ReadLimited(strings.NewReader("12345"), 5)
+	if err != nil || string(data) != "12345" {
+		t.Fatalf("Expected 12345, got %q, %v", data, err)
+	}
+
+	if _, err := ReadLimited(strings.NewReader("123456"), 5); !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("Expected ErrFileTooLarge, got %v", err)
+	}
+}
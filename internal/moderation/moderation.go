@@ -0,0 +1,294 @@
+// Package moderation checks chirp bodies against a list of word rules.
+// Each rule has an action: censor the word, reject the whole chirp or flag it for review.
+// Matching ignores case and punctuation, so "Kerfuffle!" and "ker-fuffle" match the rule "kerfuffle".
+package moderation
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+type Action string
+
+const (
+	ActionCensor Action = "censor"
+	ActionReject Action = "reject"
+	ActionFlag   Action = "flag"
+)
+
+// Censored is the text that replaces a censored word.
+const Censored = "****"
+
+// ParseAction validates an action name.
+func ParseAction(s string) (Action, error) {
+	switch action := Action(strings.ToLower(strings.TrimSpace(s))); action {
+	case ActionCensor, ActionReject, ActionFlag:
+		return action, nil
+	}
+	return "", fmt.Errorf("invalid action %q, must be censor, reject or flag", s)
+}
+
+type Rule struct {
+	Word   string `json:"word"`
+	Action Action `json:"action"`
+}
+
+// Match is a rule that matched the moderated text.
+type Match struct {
+	Word   string `json:"word"`
+	Action Action `json:"action"`
+}
+
+type Result struct {
+	// Text is the moderated text, with the censored words replaced by Censored
+	Text string
+	// Rejected is true when a reject rule matched, the text should not be published
+	Rejected bool
+	// Flagged is true when a flag rule matched, the text should be reviewed by a moderator
+	Flagged bool
+	Matches []Match
+}
+
+// Words returns the words matched by rules with the given action.
+func (r Result) Words(action Action) []string {
+	words := []string{}
+	for _, match := range r.Matches {
+		if match.Action == action {
+			words = append(words, match.Word)
+		}
+	}
+	return words
+}
+
+// Engine moderates text with a set of rules that can be replaced at runtime.
+// It is safe for concurrent use.
+type Engine struct {
+	mu    sync.RWMutex
+	rules map[string]Action
+}
+
+func NewEngine(rules []Rule) *Engine {
+	e := &Engine{}
+	e.SetRules(rules)
+	return e
+}
+
+// SetRules replaces all the rules of the engine.
+// Words are normalized, rules with an empty word are skipped and
+// when a word appears more than once the last rule wins.
+func (e *Engine) SetRules(rules []Rule) {
+	ruleMap := map[string]Action{}
+	for _, rule := range rules {
+		word := Normalize(rule.Word)
+		if word == "" {
+			continue
+		}
+		ruleMap[word] = rule.Action
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = ruleMap
+}
+
+// Rules returns the rules of the engine sorted by word.
+func (e *Engine) Rules() []Rule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	rules := make([]Rule, 0, len(e.rules))
+	for word, action := range e.rules {
+		rules = append(rules, Rule{Word: word, Action: action})
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Word < rules[j].Word })
+
+	return rules
+}
+
+// Moderate checks text against the rules.
+// The text is split on whitespace and each token is compared without its punctuation,
+// then each run of letters and digits inside the token is compared on its own,
+// so "kerfuffle's" matches "kerfuffle" too.
+// Whitespace and punctuation around a censored word are kept: "kerfuffle!" becomes "****!".
+func (e *Engine) Moderate(text string) Result {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	result := Result{}
+	matched := map[string]bool{}
+
+	match := func(word string) (Action, bool) {
+		action, ok := e.rules[word]
+		if !ok {
+			return "", false
+		}
+		if !matched[word] {
+			matched[word] = true
+			result.Matches = append(result.Matches, Match{Word: word, Action: action})
+		}
+		switch action {
+		case ActionReject:
+			result.Rejected = true
+		case ActionFlag:
+			result.Flagged = true
+		}
+		return action, true
+	}
+
+	out := strings.Builder{}
+	for _, token := range splitKeepSpaces(text) {
+		if strings.TrimSpace(token) == "" {
+			out.WriteString(token)
+			continue
+		}
+
+		// The whole token without punctuation, e.g. "ker-fuffle!"
+		if action, ok := match(Normalize(token)); ok {
+			if action == ActionCensor {
+				start, end := alnumBounds(token)
+				token = token[:start] + Censored + token[end:]
+			}
+			out.WriteString(token)
+			continue
+		}
+
+		// Each run of letters and digits, e.g. "kerfuffle" in "kerfuffle's"
+		out.WriteString(censorRuns(token, match))
+	}
+
+	result.Text = out.String()
+	return result
+}
+
+// Normalize lowercases word and removes everything that is not a letter or a digit.
+func Normalize(word string) string {
+	b := strings.Builder{}
+	for _, r := range word {
+		if isAlnum(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// LoadFile reads rules from a text file with one rule per line: the word,
+// optionally followed by its action (censor by default).
+// Empty lines and lines starting with '#' are ignored.
+//
+//	# word    action
+//	kerfuffle censor
+//	sharbert  reject
+func LoadFile(path string) ([]Rule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rules := []Rule{}
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("%s:%d: expected a word and an optional action", path, lineNumber)
+		}
+
+		rule := Rule{Word: fields[0], Action: ActionCensor}
+		if len(fields) == 2 {
+			rule.Action, err = ParseAction(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// splitKeepSpaces splits text in tokens and whitespace runs, so joining them gives back text.
+func splitKeepSpaces(text string) []string {
+	tokens := []string{}
+	start := 0
+	inSpace := false
+	for i, r := range text {
+		space := unicode.IsSpace(r)
+		if i > 0 && space != inSpace {
+			tokens = append(tokens, text[start:i])
+			start = i
+		}
+		inSpace = space
+	}
+	if start < len(text) {
+		tokens = append(tokens, text[start:])
+	}
+	return tokens
+}
+
+// censorRuns checks every run of letters and digits of token and censors the matching ones.
+func censorRuns(token string, match func(string) (Action, bool)) string {
+	out := strings.Builder{}
+	runStart := -1
+	flush := func(end int) {
+		if runStart < 0 {
+			return
+		}
+		run := token[runStart:end]
+		if action, ok := match(strings.ToLower(run)); ok && action == ActionCensor {
+			run = Censored
+		}
+		out.WriteString(run)
+		runStart = -1
+	}
+
+	for i, r := range token {
+		if isAlnum(r) {
+			if runStart < 0 {
+				runStart = i
+			}
+			continue
+		}
+		flush(i)
+		out.WriteRune(r)
+	}
+	flush(len(token))
+
+	return out.String()
+}
+
+// alnumBounds returns the byte offsets of the first letter or digit of token
+// and of the end of the last one.
+func alnumBounds(token string) (int, int) {
+	start, end := -1, 0
+	for i, r := range token {
+		if isAlnum(r) {
+			if start < 0 {
+				start = i
+			}
+			end = i + len(string(r))
+		}
+	}
+	if start < 0 {
+		return 0, 0
+	}
+	return start, end
+}
+
+func isAlnum(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,139 @@
+package moderation
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newTestEngine() *Engine {
+	return NewEngine([]Rule{
+		{Word: "kerfuffle", Action: ActionCensor},
+		{Word: "Sharbert", Action: ActionCensor},
+		{Word: "fornax", Action: ActionReject},
+		{Word: "bogus", Action: ActionFlag},
+	})
+}
+
+func TestModerate_CensorsWholeWords(t *testing.T) {
+	result := newTestEngine().Moderate("This is a kerfuffle opinion I need to share with the world")
+
+	want := "This is a **** opinion I need to share with the world"
+	if result.Text != want {
+		t.Fatalf("Expected %q, got %q", want, result.Text)
+	}
+	if result.Rejected || result.Flagged {
+		t.Fatal("Expected chirp to be neither rejected nor flagged")
+	}
+}
+
+func TestModerate_IgnoresCaseAndPunctuation(t *testing.T) {
+	result := newTestEngine().Moderate("What a Kerfuffle! (SHARBERT) ker-fuffle.")
+
+	want := "What a ****! (****) ****."
+	if result.Text != want {
+		t.Fatalf("Expected %q, got %q", want, result.Text)
+	}
+}
+
+func TestModerate_CensorsRunsInsideToken(t *testing.T) {
+	result := newTestEngine().Moderate("kerfuffle's")
+
+	if result.Text != "****'s" {
+		t.Fatalf("Expected %q, got %q", "****'s", result.Text)
+	}
+}
+
+func TestModerate_KeepsWhitespace(t *testing.T) {
+	result := newTestEngine().Moderate("  hello\tkerfuffle  ")
+
+	want := "  hello\t****  "
+	if result.Text != want {
+		t.Fatalf("Expected %q, got %q", want, result.Text)
+	}
+}
+
+func TestModerate_DoesNotMatchSubstrings(t *testing.T) {
+	result := newTestEngine().Moderate("kerfuffles are fine")
+
+	if result.Text != "kerfuffles are fine" || len(result.Matches) != 0 {
+		t.Fatalf("Expected no matches, got %q %v", result.Text, result.Matches)
+	}
+}
+
+func TestModerate_Reject(t *testing.T) {
+	result := newTestEngine().Moderate("Fornax.")
+
+	if !result.Rejected {
+		t.Fatal("Expected chirp to be rejected")
+	}
+	if !reflect.DeepEqual(result.Words(ActionReject), []string{"fornax"}) {
+		t.Fatalf("Expected fornax to be matched, got %v", result.Matches)
+	}
+}
+
+func TestModerate_Flag(t *testing.T) {
+	result := newTestEngine().Moderate("a bogus claim, so bogus")
+
+	if !result.Flagged {
+		t.Fatal("Expected chirp to be flagged")
+	}
+	if result.Text != "a bogus claim, so bogus" {
+		t.Fatalf("Expected flagged text to be unchanged, got %q", result.Text)
+	}
+	if !reflect.DeepEqual(result.Words(ActionFlag), []string{"bogus"}) {
+		t.Fatalf("Expected bogus to be matched once, got %v", result.Matches)
+	}
+}
+
+func TestSetRules_ReplacesRules(t *testing.T) {
+	engine := newTestEngine()
+	engine.SetRules([]Rule{{Word: "hello", Action: ActionCensor}})
+
+	result := engine.Moderate("hello kerfuffle")
+	if result.Text != "**** kerfuffle" {
+		t.Fatalf("Expected only the new rules to apply, got %q", result.Text)
+	}
+}
+
+func TestParseAction(t *testing.T) {
+	if _, err := ParseAction("Reject"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := ParseAction("delete"); err == nil {
+		t.Fatal("Expected error for unknown action")
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.txt")
+	content := "# comment\n\nkerfuffle\nfornax reject\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write rules file: %v", err)
+	}
+
+	rules, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	want := []Rule{
+		{Word: "kerfuffle", Action: ActionCensor},
+		{Word: "fornax", Action: ActionReject},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Fatalf("Expected %v, got %v", want, rules)
+	}
+}
+
+func TestLoadFile_InvalidAction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.txt")
+	if err := os.WriteFile(path, []byte("fornax ban\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write rules file: %v", err)
+	}
+
+	if _, err := LoadFile(path); err == nil {
+		t.Fatal("Expected error for invalid action")
+	}
+}
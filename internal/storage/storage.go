@@ -0,0 +1,138 @@
+// Package storage stores the uploaded files, such as the images attached to chirps.
+// Files are identified by a key, a relative slash-separated path like "media/3f2a.jpg".
+// The Storage interface lets the backend be swapped; Local keeps the files on disk.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ErrNotFound is returned when no file has the key.
+var ErrNotFound = errors.New("file not found")
+
+var keyRegexp = regexp.MustCompile(`^[A-Za-z0-9_\-.]+(/[A-Za-z0-9_\-.]+)*$`)
+
+// Storage saves and reads files by key.
+type Storage interface {
+	// Put saves the content of r under key, replacing the file with the same key.
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Open returns the content of the file with the key, or ErrNotFound.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the file with the key. Deleting a missing file is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// ValidKey reports whether key is a clean relative path made of letters, digits, '_', '-' and '.',
+// that cannot escape the storage root.
+func ValidKey(key string) bool {
+	return keyRegexp.MatchString(key) && path.Clean(key) == key && !hasDotDot(key)
+}
+
+func hasDotDot(key string) bool {
+	for _, part := range strings.Split(key, "/") {
+		if part == "." || part == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// Local stores the files in a directory of the local disk.
+type Local struct {
+	Dir string
+}
+
+// NewLocal returns a storage in dir, which is created if needed.
+func NewLocal(dir string) (*Local, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Local{Dir: dir}, nil
+}
+
+func (l *Local) path(key string) (string, error) {
+	if !ValidKey(key) {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return filepath.Join(l.Dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes the file to a temporary file first and renames it,
+// so a file is never read while partially written.
+func (l *Local) Put(ctx context.Context, key string, r io.Reader) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), p)
+}
+
+func (l *Local) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, ErrNotFound
+	}
+
+	return f, nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
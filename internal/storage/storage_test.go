@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"media/3f2a.jpg", true},
+		{"avatar.png", true},
+		{"media/thumbs/3f2a_thumb.jpg", true},
+		{"", false},
+		{"/etc/passwd", false},
+		{"../secret", false},
+		{"media/../../secret", false},
+		{"..", false},
+		{"media/./a.jpg", false},
+		{"media//a.jpg", false},
+		{"media/", false},
+		{"media\\a.jpg", false},
+		{"media/a b.jpg", false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidKey(tt.key); got != tt.want {
+			t.Errorf("ValidKey(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestLocal_PutOpenDelete(t *testing.T) {
+	ctx := context.Background()
+	l, err := NewLocal(filepath.Join(t.TempDir(), "media"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := l.Put(ctx, "media/a.txt", strings.NewReader("first")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := l.Put(ctx, "media/a.txt", strings.NewReader("second")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	f, err := l.Open(ctx, "media/a.txt")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	content, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if string(content) != "second" {
+		t.Fatalf("Expected content %q, got %q", "second", content)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(l.Dir, "media"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected no temporary file left, got %d entries", len(entries))
+	}
+
+	if err := l.Delete(ctx, "media/a.txt"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := l.Open(ctx, "media/a.txt"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+	if err := l.Delete(ctx, "media/a.txt"); err != nil {
+		t.Fatalf("Expected no error deleting a missing file, got %v", err)
+	}
+}
+
+func TestLocal_InvalidKeys(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	l, err := NewLocal(filepath.Join(root, "media"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := l.Put(ctx, "../escaped.txt", strings.NewReader("x")); err == nil {
+		t.Fatal("Expected an error for a key outside the storage")
+	}
+	if _, err := os.Stat(filepath.Join(root, "escaped.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Expected no file outside the storage, got %v", err)
+	}
+
+	if _, err := l.Open(ctx, "../media"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+
+	if err := l.Put(ctx, "dir/a.txt", strings.NewReader("x")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := l.Open(ctx, "dir"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound for a directory, got %v", err)
+	}
+}
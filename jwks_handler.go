@@ -0,0 +1,16 @@
+package main
+
+import (
+	"net/http"
+)
+
+// handlerJWKS publishes the public keys the access tokens can be verified with,
+// so other services can verify Chirpy tokens without sharing a secret.
+// It lists an empty set when the tokens are signed with the API_KEY secret.
+// It is registered as a handler for the "/.well-known/jwks.json" endpoint with the GET method.
+func (c *apiConfig) handlerJWKS(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	marshalOkJson(w, http.StatusOK, c.jwtKeys.JWKS())
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/federicoReghini/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// handlerLikeChirp adds a like of the authenticated user to the chirp in the path.
+// A user can like a chirp only once, liking it again is a no-op.
+// It returns 204 No Content on success and 404 if the chirp does not exist.
+// It is registered as a handler for the "/chirps/{chirpID}/likes" endpoint with the POST method.
+func (c *apiConfig) handlerLikeChirp(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	userID := userIDFromContext(req.Context())
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	if _, err := c.db.GetChirp(req.Context(), chirpID); err != nil {
+		marshalError(w, http.StatusNotFound, "Chirp not found")
+		return
+	}
+
+	err = c.db.LikeChirp(req.Context(), database.LikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerUnlikeChirp removes the like of the authenticated user from the chirp in the path.
+// Removing a like that does not exist is a no-op.
+// It is registered as a handler for the "/chirps/{chirpID}/likes" endpoint with the DELETE method.
+func (c *apiConfig) handlerUnlikeChirp(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	userID := userIDFromContext(req.Context())
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	err = c.db.UnlikeChirp(req.Context(), database.UnlikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,24 +1,76 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/federicoReghini/Chirpy/internal/auth"
 	"github.com/federicoReghini/Chirpy/internal/database"
+	"github.com/federicoReghini/Chirpy/internal/lockout"
+	"github.com/federicoReghini/Chirpy/internal/mailer"
+	"github.com/federicoReghini/Chirpy/internal/moderation"
+	"github.com/federicoReghini/Chirpy/internal/storage"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
+// Defaults of the settings
+const (
+	defaultJWTAudience = "chirpy-api"
+	defaultJWTLeeway   = 30 * time.Second
+	defaultBaseURL     = "http://localhost:8080"
+	defaultMailerDir   = "./mail"
+	defaultMailerFrom  = "no-reply@chirpy.local"
+	defaultMediaDir    = "./media"
+)
+
+// shutdownTimeout is how long the requests in progress have to finish on shutdown.
+const shutdownTimeout = 10 * time.Second
+
 type apiConfig struct {
 	fileServerHits atomic.Int32
 	db             *database.Queries
-	platform       string
-	apiKey         string
-	polkaKey       string
+	sqlDB          *sql.DB
+	trending       *trendingTracker
+	moderation     *moderation.Engine
+	// moderationFileRules are the read-only rules of MODERATION_RULES_FILE
+	moderationFileRules []moderation.Rule
+	platform            string
+	// jwtKeys sign the access tokens, jwtValidator checks them
+	jwtKeys      *auth.KeySet
+	jwtValidator *auth.Validator
+	// jwtAudience is the audience of the access tokens
+	jwtAudience string
+	// loginAccountLockout and loginIPLockout count the login attempts per email and per IP address
+	loginAccountLockout *lockout.Tracker
+	loginIPLockout      *lockout.Tracker
+	// verificationResendLimit counts the verification emails asked for per user
+	verificationResendLimit *lockout.Tracker
+	mailer                  mailer.Mailer
+	// passwordResets queues the emails to send a password reset token to (see runPasswordResetWorker)
+	passwordResets chan string
+	// passwordResetEmailLimit and passwordResetIPLimit count the password reset requests
+	// per email and per IP address
+	passwordResetEmailLimit *lockout.Tracker
+	passwordResetIPLimit    *lockout.Tracker
+	// storage keeps the uploaded images
+	storage storage.Storage
+	// mediaProcessing bounds the uploaded images processed at the same time
+	mediaProcessing chan struct{}
+	// baseURL is the public URL of the server, used in the links sent by email
+	baseURL  string
+	polkaKey string
 }
 
 func (c *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -46,6 +98,92 @@ func createApiPath(method, prefix, path string) string {
 	return fmt.Sprintf("%s %s%s", method, prefix, path)
 }
 
+// registerRoutes registers every endpoint but the /app/ file server on serveMux.
+func (c *apiConfig) registerRoutes(serveMux *http.ServeMux) {
+	const apiPrefix = "/api/"
+	const adminPrefix = "/admin/"
+
+	// api generic resource
+	// Routes needing a user go through middlewareAuth (middlewareRequireVerifiedEmail to post),
+	// the public routes whose response depends on the viewer through middlewareOptionalAuth,
+	// and every /admin/ route through middlewareRequireRole
+	serveMux.HandleFunc(createApiPath("GET", apiPrefix, "healthz"), handlerHealth)
+	serveMux.HandleFunc("GET /.well-known/jwks.json", c.handlerJWKS)
+	serveMux.HandleFunc("GET /media/{key...}", c.handlerServeMedia)
+	serveMux.HandleFunc(createApiPath("GET", adminPrefix, "metrics"), c.middlewareRequireRole(auth.RoleAdmin, c.handlerMetrics))
+	serveMux.HandleFunc(createApiPath("POST ", apiPrefix, "login"), c.handlerLogin)
+	serveMux.HandleFunc(createApiPath("POST ", apiPrefix, "refresh"), c.handlerRefreshToken)
+	serveMux.HandleFunc(createApiPath("POST ", apiPrefix, "revoke"), c.handlerRefreshTokenRevoke)
+	// Sessions resource
+	serveMux.HandleFunc(createApiPath("GET ", apiPrefix, "sessions"), c.middlewareAuth(c.handlerGetSessions))
+	serveMux.HandleFunc(createApiPath("DELETE ", apiPrefix, "sessions"), c.middlewareAuth(c.handlerRevokeAllSessions))
+	serveMux.HandleFunc(createApiPath("DELETE ", apiPrefix, "sessions/{sessionID}"), c.middlewareAuth(c.handlerRevokeSession))
+	// Chirps resource
+	serveMux.HandleFunc(createApiPath("POST ", apiPrefix, "chirps"), c.middlewareRequireVerifiedEmail(c.handlerCreateChirp))
+	serveMux.HandleFunc(createApiPath("GET ", apiPrefix, "chirps"), c.middlewareOptionalAuth(c.handlerGetChips))
+	serveMux.HandleFunc(createApiPath("GET ", apiPrefix, "chirps/search"), c.middlewareOptionalAuth(c.handlerSearchChirps))
+	serveMux.HandleFunc(createApiPath("GET ", apiPrefix, "chirps/{chirpID}"), c.middlewareOptionalAuth(c.handlerGetChipByID))
+	serveMux.HandleFunc(createApiPath("PUT ", apiPrefix, "chirps/{chirpID}"), c.middlewareAuth(c.handlerUpdateChirp))
+	serveMux.HandleFunc(createApiPath("PATCH ", apiPrefix, "chirps/{chirpID}"), c.middlewareAuth(c.handlerUpdateChirp))
+	serveMux.HandleFunc(createApiPath("DELETE ", apiPrefix, "chirps/{chirpID}"), c.middlewareAuth(c.handlerDeleteChirp))
+	serveMux.HandleFunc(createApiPath("GET ", apiPrefix, "chirps/{chirpID}/revisions"), c.handlerGetChirpRevisions)
+	serveMux.HandleFunc(createApiPath("GET ", apiPrefix, "chirps/{chirpID}/replies"), c.middlewareOptionalAuth(c.handlerGetChirpReplies))
+	serveMux.HandleFunc(createApiPath("GET ", apiPrefix, "chirps/{chirpID}/conversation"), c.middlewareOptionalAuth(c.handlerGetChirpConversation))
+	serveMux.HandleFunc(createApiPath("POST ", apiPrefix, "chirps/{chirpID}/rechirp"), c.middlewareRequireVerifiedEmail(c.handlerRechirp))
+	serveMux.HandleFunc(createApiPath("DELETE ", apiPrefix, "chirps/{chirpID}/rechirp"), c.middlewareAuth(c.handlerUndoRechirp))
+	serveMux.HandleFunc(createApiPath("POST ", apiPrefix, "chirps/{chirpID}/likes"), c.middlewareAuth(c.handlerLikeChirp))
+	serveMux.HandleFunc(createApiPath("DELETE ", apiPrefix, "chirps/{chirpID}/likes"), c.middlewareAuth(c.handlerUnlikeChirp))
+	// Users resource
+	serveMux.HandleFunc(createApiPath("POST ", apiPrefix, "users"), c.handlerCreateUser)
+	serveMux.HandleFunc(createApiPath("PUT ", apiPrefix, "users"), c.middlewareAuth(c.handlerUpdateUser))
+	serveMux.HandleFunc(createApiPath("PATCH ", apiPrefix, "users"), c.middlewareAuth(c.handlerUpdateUser))
+	serveMux.HandleFunc(createApiPath("GET ", apiPrefix, "users/{userID}"), c.handlerGetUser)
+	// "users/by-username/{handle}" would conflict with "users/{userID}/mentions" and the like
+	// (both match "users/by-username/mentions"), the handler checks the first segment itself.
+	// The GET routes of "users/{userID}/" win, so their last segment must be in reservedUsernames
+	serveMux.HandleFunc(createApiPath("GET ", apiPrefix, "users/{segment}/{handle}"), c.handlerGetUserByUsername)
+	serveMux.HandleFunc(createApiPath("GET ", apiPrefix, "verify-email"), c.handlerVerifyEmail)
+	serveMux.HandleFunc(createApiPath("POST ", apiPrefix, "verify-email/resend"), c.middlewareAuth(c.handlerResendVerificationEmail))
+	serveMux.HandleFunc(createApiPath("POST ", apiPrefix, "password-reset"), c.handlerRequestPasswordReset)
+	serveMux.HandleFunc(createApiPath("POST ", apiPrefix, "password-reset/confirm"), c.handlerConfirmPasswordReset)
+	serveMux.HandleFunc(createApiPath("POST ", adminPrefix, "reset"), c.middlewareRequireRole(auth.RoleAdmin, c.handlerReset))
+	serveMux.HandleFunc(createApiPath("PUT ", adminPrefix, "users/{userID}/role"), c.middlewareRequireRole(auth.RoleAdmin, c.handlerUpdateUserRole))
+	serveMux.HandleFunc(createApiPath("GET ", apiPrefix, "users/{userID}/mentions"), c.middlewareOptionalAuth(c.handlerGetUserMentions))
+	// Moderation resource
+	serveMux.HandleFunc(createApiPath("GET ", adminPrefix, "moderation/rules"), c.middlewareRequireRole(auth.RoleAdmin, c.handlerGetModerationRules))
+	serveMux.HandleFunc(createApiPath("PUT ", adminPrefix, "moderation/rules/{word}"), c.middlewareRequireRole(auth.RoleAdmin, c.handlerPutModerationRule))
+	serveMux.HandleFunc(createApiPath("DELETE ", adminPrefix, "moderation/rules/{word}"), c.middlewareRequireRole(auth.RoleAdmin, c.handlerDeleteModerationRule))
+	serveMux.HandleFunc(createApiPath("GET ", adminPrefix, "moderation/flags"), c.middlewareRequireRole(auth.RoleModerator, c.handlerGetChirpFlags))
+	serveMux.HandleFunc(createApiPath("POST ", adminPrefix, "moderation/flags/{flagID}/review"), c.middlewareRequireRole(auth.RoleModerator, c.handlerReviewChirpFlag))
+	// Reports resource
+	serveMux.HandleFunc(createApiPath("POST ", apiPrefix, "chirps/{chirpID}/reports"), c.middlewareAuth(c.handlerCreateReport))
+	serveMux.HandleFunc(createApiPath("GET ", adminPrefix, "reports"), c.middlewareRequireRole(auth.RoleModerator, c.handlerGetOpenReports))
+	serveMux.HandleFunc(createApiPath("POST ", adminPrefix, "reports/{reportID}/resolve"), c.middlewareRequireRole(auth.RoleModerator, c.handlerResolveReport))
+	serveMux.HandleFunc(createApiPath("POST ", adminPrefix, "chirps/{chirpID}/unhide"), c.middlewareRequireRole(auth.RoleModerator, c.handlerUnhideChirp))
+	serveMux.HandleFunc(createApiPath("GET ", adminPrefix, "moderation/actions"), c.middlewareRequireRole(auth.RoleModerator, c.handlerGetModerationActions))
+	// Follows resource
+	serveMux.HandleFunc(createApiPath("POST ", apiPrefix, "users/{userID}/follow"), c.middlewareAuth(c.handlerFollowUser))
+	serveMux.HandleFunc(createApiPath("DELETE ", apiPrefix, "users/{userID}/follow"), c.middlewareAuth(c.handlerUnfollowUser))
+	serveMux.HandleFunc(createApiPath("GET ", apiPrefix, "users/{userID}/followers"), c.handlerGetFollowers)
+	serveMux.HandleFunc(createApiPath("GET ", apiPrefix, "users/{userID}/following"), c.handlerGetFollowing)
+	serveMux.HandleFunc(createApiPath("GET ", apiPrefix, "timeline"), c.middlewareAuth(c.handlerGetTimeline))
+	// Hashtags resource
+	serveMux.HandleFunc(createApiPath("GET ", apiPrefix, "hashtags/{tag}/chirps"), c.middlewareOptionalAuth(c.handlerGetHashtagChirps))
+	serveMux.HandleFunc(createApiPath("GET ", apiPrefix, "trending"), c.handlerGetTrending)
+	// Media resource
+	serveMux.HandleFunc(createApiPath("POST ", apiPrefix, "media"), c.middlewareRequireVerifiedEmail(c.handlerUploadMedia))
+	// Webhook resource
+	serveMux.HandleFunc(createApiPath("POST ", apiPrefix, "polka/webhooks"), c.handlerPolkaWebhook)
+}
+
+// envOrDefault returns the value of the environment variable key, or def when it is empty.
+func envOrDefault(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
 func main() {
 	godotenv.Load()
 
@@ -62,45 +200,137 @@ func main() {
 
 	serveMux := http.NewServeMux()
 	const port = "8080"
-	const filepathRoot = "."
-	const apiPrefix = "/api/"
+	// Only the static directory is served under /app/, the data directories must stay out of it
+	const filepathRoot = "./static"
 	const appPrefix = "/app/"
-	const adminPrefix = "/admin/"
 
 	apiCfg := &apiConfig{
-		fileServerHits: atomic.Int32{},
-		db:             dbQueries,
-		platform:       os.Getenv("PLATFORM"),
-		apiKey:         os.Getenv("API_KEY"),
-		polkaKey:       os.Getenv("POLKA_KEY"),
+		fileServerHits:  atomic.Int32{},
+		db:              dbQueries,
+		sqlDB:           db,
+		trending:        &trendingTracker{},
+		passwordResets:  make(chan string, passwordResetQueueSize),
+		mediaProcessing: make(chan struct{}, maxConcurrentMediaProcessing),
+		platform:        os.Getenv("PLATFORM"),
+		polkaKey:        os.Getenv("POLKA_KEY"),
+		moderation:      moderation.NewEngine(nil),
+
+		loginAccountLockout:     lockout.NewTracker(loginAccountPolicy),
+		loginIPLockout:          lockout.NewTracker(loginIPPolicy),
+		verificationResendLimit: lockout.NewTracker(resendVerificationPolicy),
+		passwordResetEmailLimit: lockout.NewTracker(passwordResetEmailPolicy),
+		passwordResetIPLimit:    lockout.NewTracker(passwordResetIPPolicy),
+	}
+
+	// JWTs are signed with the keys of JWT_KEYS_DIR when it is set,
+	// and with the API_KEY secret (HS256) otherwise
+	if keysDir := os.Getenv("JWT_KEYS_DIR"); keysDir != "" {
+		apiCfg.jwtKeys, err = auth.LoadKeySet(keysDir, os.Getenv("JWT_SIGNING_KEY_ID"))
+		if err != nil {
+			log.Fatalf("Error loading JWT keys: %s", err)
+		}
+	} else {
+		apiCfg.jwtKeys = auth.NewHMACKeySet(os.Getenv("API_KEY"))
+	}
+
+	apiCfg.jwtAudience = envOrDefault("JWT_AUDIENCE", defaultJWTAudience)
+	jwtLeeway := defaultJWTLeeway
+	if leeway := os.Getenv("JWT_LEEWAY"); leeway != "" {
+		jwtLeeway, err = time.ParseDuration(leeway)
+		if err != nil {
+			log.Fatalf("Invalid JWT_LEEWAY: %s", err)
+		}
+	}
+	apiCfg.jwtValidator, err = auth.NewValidator(apiCfg.jwtKeys, auth.ValidatorConfig{
+		Audience: apiCfg.jwtAudience,
+		Leeway:   jwtLeeway,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	apiCfg.baseURL = strings.TrimSuffix(envOrDefault("BASE_URL", defaultBaseURL), "/")
+
+	// Emails are logged by default, MAILER=file saves them in MAILER_DIR
+	switch os.Getenv("MAILER") {
+	case "", "log":
+		apiCfg.mailer = mailer.NewLogMailer()
+	case "file":
+		mailerDir := envOrDefault("MAILER_DIR", defaultMailerDir)
+		if dirInside(mailerDir, filepathRoot) {
+			log.Fatalf("MAILER_DIR %s must not be inside %s, which is served under %s", mailerDir, filepathRoot, appPrefix)
+		}
+		apiCfg.mailer, err = mailer.NewFileMailer(mailerDir, envOrDefault("MAILER_FROM", defaultMailerFrom))
+		if err != nil {
+			log.Fatalf("Error creating the mail directory: %s", err)
+		}
+	default:
+		log.Fatalf("Unknown MAILER %q, must be log or file", os.Getenv("MAILER"))
+	}
+
+	// The uploads are only served by handlerServeMedia, with their content type and nosniff
+	mediaDir := envOrDefault("MEDIA_DIR", defaultMediaDir)
+	if dirInside(mediaDir, filepathRoot) {
+		log.Fatalf("MEDIA_DIR %s must not be inside %s, which is served under %s", mediaDir, filepathRoot, appPrefix)
+	}
+	apiCfg.storage, err = storage.NewLocal(mediaDir)
+	if err != nil {
+		log.Fatalf("Error creating the media directory: %s", err)
+	}
+
+	if rulesFile := os.Getenv("MODERATION_RULES_FILE"); rulesFile != "" {
+		apiCfg.moderationFileRules, err = moderation.LoadFile(rulesFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := apiCfg.reloadModerationRules(context.Background()); err != nil {
+		log.Fatalf("Error loading moderation rules: %s", err)
 	}
 
 	// app resource
 	serveMux.Handle(appPrefix, apiCfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot)))))
-	// api generic resource
-	serveMux.HandleFunc(createApiPath("GET", apiPrefix, "healthz"), handlerHealth)
-	serveMux.HandleFunc(createApiPath("GET", adminPrefix, "metrics"), apiCfg.handlerMetrics)
-	serveMux.HandleFunc(createApiPath("POST ", apiPrefix, "login"), apiCfg.handlerLogin)
-	serveMux.HandleFunc(createApiPath("POST ", apiPrefix, "refresh"), apiCfg.handlerRefreshToken)
-	serveMux.HandleFunc(createApiPath("POST ", apiPrefix, "revoke"), apiCfg.handlerRefreshTokenRevoke)
-	// Chirps resource
-	serveMux.HandleFunc(createApiPath("POST ", apiPrefix, "chirps"), apiCfg.handlerCreateChirp)
-	serveMux.HandleFunc(createApiPath("GET ", apiPrefix, "chirps"), apiCfg.handlerGetChips)
-	serveMux.HandleFunc(createApiPath("GET ", apiPrefix, "chirps/{chirpID}"), apiCfg.handlerGetChipByID)
-	serveMux.HandleFunc(createApiPath("DELETE ", apiPrefix, "chirps/{chirpID}"), apiCfg.handlerDeleteChirp)
-	// Users resource
-	serveMux.HandleFunc(createApiPath("POST ", apiPrefix, "users"), apiCfg.handlerCreateUser)
-	serveMux.HandleFunc(createApiPath("PUT ", apiPrefix, "users"), apiCfg.handlerUpdateUser)
-	serveMux.HandleFunc(createApiPath("POST ", adminPrefix, "reset"), apiCfg.handlerReset)
-	// Webhook resource
-	serveMux.HandleFunc(createApiPath("POST ", apiPrefix, "polka/webhooks"), apiCfg.handlerPolkaWebhook)
+	apiCfg.registerRoutes(serveMux)
 
 	server := &http.Server{
 		Addr:    ":" + port,
 		Handler: serveMux,
 	}
 
-	log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
+	// The server and the background workers stop on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var workers sync.WaitGroup
+	workers.Add(3)
+	go func() {
+		defer workers.Done()
+		apiCfg.runTrendingWorker(ctx, trendingInterval)
+	}()
+	go func() {
+		defer workers.Done()
+		apiCfg.runPasswordResetWorker(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		apiCfg.runMediaCleanupWorker(ctx, mediaCleanupInterval)
+	}()
+
+	go func() {
+		log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
 
-	log.Fatal(server.ListenAndServe())
+	<-ctx.Done()
+	log.Println("Shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down the server: %s", err)
+	}
+	workers.Wait()
 }
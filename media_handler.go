@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/federicoReghini/Chirpy/internal/auth"
+	"github.com/federicoReghini/Chirpy/internal/database"
+	"github.com/federicoReghini/Chirpy/internal/media"
+	"github.com/federicoReghini/Chirpy/internal/storage"
+	"github.com/google/uuid"
+)
+
+// Limits of the uploads
+const (
+	// maxUploadSize is the largest image that can be uploaded, in bytes
+	maxUploadSize = 5 << 20
+	// maxChirpMedia is the number of images that can be attached to a chirp
+	maxChirpMedia = 4
+	// maxConcurrentMediaProcessing is the number of images decoded at the same time,
+	// each can take up to 64 MB of memory (see media.MaxPixels)
+	maxConcurrentMediaProcessing = 2
+)
+
+// Cleanup of the uploads never attached to a chirp nor used as an avatar
+const (
+	// unattachedMediaTTL is how long after its upload an image can still be attached
+	unattachedMediaTTL = 24 * time.Hour
+	// mediaCleanupInterval is how often the unattached images are removed
+	mediaCleanupInterval = time.Hour
+)
+
+// mediaResponse is an uploaded image as returned by the API.
+// The storage keys are not returned, only the URLs the files are served at.
+type mediaResponse struct {
+	ID           uuid.UUID `json:"id"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	ContentType  string    `json:"content_type"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+	SizeBytes    int64     `json:"size_bytes"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (c *apiConfig) newMediaResponse(m database.Media) mediaResponse {
+	return mediaResponse{
+		ID:           m.ID,
+		URL:          c.mediaURL(m.StorageKey),
+		ThumbnailURL: c.mediaURL(m.ThumbnailKey),
+		ContentType:  m.ContentType,
+		Width:        m.Width,
+		Height:       m.Height,
+		SizeBytes:    m.SizeBytes,
+		CreatedAt:    m.CreatedAt,
+	}
+}
+
+// mediaURL returns the public URL of the file with the storage key, served by handlerServeMedia.
+func (c *apiConfig) mediaURL(key string) string {
+	return c.baseURL + "/media/" + key
+}
+
+// getChirpsMedia returns the images attached to the chirps, by chirp ID, in their order.
+func (c *apiConfig) getChirpsMedia(ctx context.Context, chirpIDs []uuid.UUID) (map[uuid.UUID][]mediaResponse, error) {
+	dbMedia, err := c.db.GetChirpsMedia(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaByChirp := map[uuid.UUID][]mediaResponse{}
+	for _, m := range dbMedia {
+		mediaByChirp[m.ChirpID.UUID] = append(mediaByChirp[m.ChirpID.UUID], c.newMediaResponse(m))
+	}
+	return mediaByChirp, nil
+}
+
+// attachChirpMedia attaches the uploaded images to a new chirp, in the order of mediaIDs.
+// It returns false if an image does not exist, is not the author's or is already attached.
+func attachChirpMedia(ctx context.Context, q *database.Queries, chirp database.Chirp, mediaIDs []uuid.UUID) (bool, error) {
+	for i, mediaID := range mediaIDs {
+		attached, err := q.AttachMediaToChirp(ctx, database.AttachMediaToChirpParams{
+			ChirpID:  uuid.NullUUID{UUID: chirp.ID, Valid: true},
+			Position: int32(i),
+			ID:       mediaID,
+			UserID:   chirp.UserID.UUID,
+		})
+		if err != nil {
+			return false, err
+		}
+		if attached == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// handlerUploadMedia saves the image of the "file" field of a multipart form, and a thumbnail of it.
+// The image must be a JPEG, PNG or GIF of at most 5 MB, the type is detected from the content.
+// The returned ID can be sent in the media_ids of a new chirp or as the avatar_media_id of the user,
+// within unattachedMediaTTL: the images left unused are removed after it.
+// It returns 413 if the file is too large and 415 if it is not a supported image.
+// Only maxConcurrentMediaProcessing images are processed at a time, the other uploads wait.
+// It is registered as a handler for the "/api/media" endpoint with the POST method.
+func (c *apiConfig) handlerUploadMedia(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	userID := userIDFromContext(req.Context())
+
+	// The limit leaves room for the multipart headers around the file
+	req.Body = http.MaxBytesReader(w, req.Body, maxUploadSize+1<<20)
+	file, _, err := req.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			marshalError(w, http.StatusRequestEntityTooLarge, "File is too large")
+			return
+		}
+		marshalError(w, http.StatusBadRequest, "Missing file")
+		return
+	}
+	defer file.Close()
+
+	data, err := media.ReadLimited(file, maxUploadSize)
+	if errors.Is(err, media.ErrFileTooLarge) {
+		marshalError(w, http.StatusRequestEntityTooLarge, "File is too large")
+		return
+	}
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid file")
+		return
+	}
+
+	select {
+	case c.mediaProcessing <- struct{}{}:
+	case <-req.Context().Done():
+		return
+	}
+	img, err := media.Process(data)
+	<-c.mediaProcessing
+	if errors.Is(err, media.ErrUnsupportedType) {
+		marshalError(w, http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+	if errors.Is(err, media.ErrInvalidImage) || errors.Is(err, media.ErrTooLarge) {
+		marshalError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// The keys are random, so the files can be cached forever and cannot be guessed
+	name, err := auth.MakeToken()
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	storageKey := "images/" + name + media.Extension(img.ContentType)
+	thumbnailKey := "thumbnails/" + name + media.Extension(img.ThumbnailType)
+
+	if err := c.storage.Put(req.Context(), storageKey, bytes.NewReader(data)); err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := c.storage.Put(req.Context(), thumbnailKey, bytes.NewReader(img.Thumbnail)); err != nil {
+		c.deleteMediaFiles(storageKey)
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	dbMedia, err := c.db.CreateMedia(req.Context(), database.CreateMediaParams{
+		UserID:       userID,
+		ContentType:  img.ContentType,
+		SizeBytes:    int64(len(data)),
+		Width:        int32(img.Width),
+		Height:       int32(img.Height),
+		StorageKey:   storageKey,
+		ThumbnailKey: thumbnailKey,
+	})
+	if err != nil {
+		c.deleteMediaFiles(storageKey, thumbnailKey)
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	marshalOkJson(w, http.StatusCreated, c.newMediaResponse(dbMedia))
+}
+
+// deleteChirpMedia deletes the images attached to a chirp about to be deleted, and returns
+// the keys of their files, to remove with deleteMediaFiles once the transaction is committed.
+func deleteChirpMedia(ctx context.Context, q *database.Queries, chirpID uuid.UUID) ([]string, error) {
+	rows, err := q.DeleteChirpMedia(ctx, uuid.NullUUID{UUID: chirpID, Valid: true})
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	for _, row := range rows {
+		keys = append(keys, row.StorageKey, row.ThumbnailKey)
+	}
+	return keys, nil
+}
+
+// cleanupMedia deletes the images uploaded more than unattachedMediaTTL ago and never
+// attached to a chirp nor used as an avatar, and their files.
+func (c *apiConfig) cleanupMedia(ctx context.Context) error {
+	rows, err := c.db.DeleteUnattachedMedia(ctx, time.Now().Add(-unattachedMediaTTL))
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		c.deleteMediaFiles(row.StorageKey, row.ThumbnailKey)
+	}
+	if len(rows) > 0 {
+		log.Printf("Removed %d unattached images", len(rows))
+	}
+	return nil
+}
+
+// runMediaCleanupWorker runs cleanupMedia right away and then every interval,
+// until ctx is done. Errors are logged and the next run tries again.
+func (c *apiConfig) runMediaCleanupWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.cleanupMedia(ctx); err != nil {
+			log.Printf("Error removing unattached images: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deleteMediaFiles removes the files of deleted images or of a failed upload.
+// A failure is only logged, the file stays in the storage.
+func (c *apiConfig) deleteMediaFiles(keys ...string) {
+	for _, key := range keys {
+		if err := c.storage.Delete(context.Background(), key); err != nil {
+			log.Printf("Error deleting media file %s: %s", key, err)
+		}
+	}
+}
+
+// handlerServeMedia serves the uploaded images and their thumbnails from the storage.
+// The content type comes from the extension of the key, which the upload derived from the content,
+// and the browser is told not to guess another one.
+// It is registered as a handler for the "/media/{key...}" endpoint with the GET method.
+func (c *apiConfig) handlerServeMedia(w http.ResponseWriter, req *http.Request) {
+	key := req.PathValue("key")
+
+	contentType := ""
+	for _, t := range []string{media.TypeJPEG, media.TypePNG, media.TypeGIF} {
+		if path.Ext(key) == media.Extension(t) {
+			contentType = t
+		}
+	}
+	if contentType == "" || !storage.ValidKey(key) {
+		http.NotFound(w, req)
+		return
+	}
+
+	file, err := c.storage.Open(req.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.NotFound(w, req)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Could not read the file", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, file)
+}
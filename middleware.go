@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/federicoReghini/Chirpy/internal/auth"
+	"github.com/google/uuid"
+)
+
+type contextKey string
+
+const claimsContextKey contextKey = "claims"
+
+// authenticate validates the bearer token of the request and returns its claims.
+func (c *apiConfig) authenticate(req *http.Request) (*auth.Claims, error) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.jwtValidator.Validate(token)
+}
+
+// tokenErrorMessage returns the message of the 401 response for a rejected bearer token,
+// telling the client whether to refresh the token or log in again.
+func tokenErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, auth.ErrTokenExpired):
+		return "Token expired"
+	case errors.Is(err, auth.ErrTokenSignatureInvalid):
+		return "Invalid token signature"
+	case errors.Is(err, auth.ErrTokenInvalidAudience):
+		return "Invalid token audience"
+	case errors.Is(err, auth.ErrTokenInvalidIssuer):
+		return "Invalid token issuer"
+	case errors.Is(err, auth.ErrTokenNotValidYet):
+		return "Token not valid yet"
+	default:
+		return "Invalid or missing token"
+	}
+}
+
+// withClaims returns a copy of the request carrying the claims in its context.
+func withClaims(req *http.Request, claims *auth.Claims) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), claimsContextKey, claims))
+}
+
+// middlewareAuth only lets through the requests with a valid bearer token.
+// It responds 401 Unauthorized otherwise, with a message telling why, see tokenErrorMessage.
+// The claims of the token are stored in the request context, see userIDFromContext.
+func (c *apiConfig) middlewareAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		claims, err := c.authenticate(req)
+		if err != nil {
+			marshalError(w, http.StatusUnauthorized, tokenErrorMessage(err))
+			return
+		}
+
+		next(w, withClaims(req, claims))
+	}
+}
+
+// middlewareOptionalAuth stores the claims of the bearer token in the request context when there is a valid one.
+// A missing or invalid token is not an error: the request goes through as anonymous.
+// It is used by the public endpoints whose response depends on the viewer, see optionalUserIDFromContext.
+func (c *apiConfig) middlewareOptionalAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		claims, err := c.authenticate(req)
+		if err != nil {
+			next(w, req)
+			return
+		}
+
+		next(w, withClaims(req, claims))
+	}
+}
+
+// middlewareRequireRole works like middlewareAuth and also requires
+// the role of the token to have the permissions of the required role.
+// It responds 403 Forbidden when the role is not enough.
+func (c *apiConfig) middlewareRequireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return c.middlewareAuth(func(w http.ResponseWriter, req *http.Request) {
+		if !auth.HasRole(claimsFromContext(req.Context()).Role, role) {
+			marshalError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		next(w, req)
+	})
+}
+
+// claimsFromContext returns the claims stored by the auth middlewares.
+// It returns nil when the request is anonymous.
+func claimsFromContext(ctx context.Context) *auth.Claims {
+	claims, _ := ctx.Value(claimsContextKey).(*auth.Claims)
+	return claims
+}
+
+// userIDFromContext returns the ID of the authenticated user.
+// It must only be used behind middlewareAuth or middlewareRequireRole,
+// it returns uuid.Nil for an anonymous request.
+func userIDFromContext(ctx context.Context) uuid.UUID {
+	claims := claimsFromContext(ctx)
+	if claims == nil {
+		return uuid.Nil
+	}
+	return claims.UserID()
+}
+
+// optionalUserIDFromContext returns the ID of the authenticated user, if any.
+// It is meant for the handlers behind middlewareOptionalAuth.
+func optionalUserIDFromContext(ctx context.Context) uuid.NullUUID {
+	claims := claimsFromContext(ctx)
+	if claims == nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: claims.UserID(), Valid: true}
+}
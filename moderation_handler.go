@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/federicoReghini/Chirpy/internal/database"
+	"github.com/federicoReghini/Chirpy/internal/moderation"
+	"github.com/google/uuid"
+)
+
+type moderationRuleResponse struct {
+	Word   string            `json:"word"`
+	Action moderation.Action `json:"action"`
+	// Source is "database" for the rules managed through the admin endpoints
+	// and "file" for the read-only rules of MODERATION_RULES_FILE
+	Source string `json:"source"`
+}
+
+type moderationRuleRequest struct {
+	Action string `json:"action"`
+}
+
+type chirpFlagResponse struct {
+	ID           uuid.UUID `json:"id"`
+	ChirpID      uuid.UUID `json:"chirp_id"`
+	MatchedWords []string  `json:"matched_words"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// reloadModerationRules loads the rules of the database on top of the rules of
+// the file and replaces the rules of the moderation engine.
+// A word defined in both places uses the action of the database rule.
+func (c *apiConfig) reloadModerationRules(ctx context.Context) error {
+	dbRules, err := c.db.GetModerationRules(ctx)
+	if err != nil {
+		return err
+	}
+
+	rules := make([]moderation.Rule, 0, len(c.moderationFileRules)+len(dbRules))
+	rules = append(rules, c.moderationFileRules...)
+	for _, dbRule := range dbRules {
+		rules = append(rules, moderation.Rule{
+			Word:   dbRule.Word,
+			Action: moderation.Action(dbRule.Action),
+		})
+	}
+
+	c.moderation.SetRules(rules)
+	return nil
+}
+
+// flagChirp records a chirp for moderator review when a flag rule matched its body.
+// It does nothing when the chirp was not flagged.
+func flagChirp(ctx context.Context, q *database.Queries, chirpID uuid.UUID, result moderation.Result) error {
+	if !result.Flagged {
+		return nil
+	}
+
+	_, err := q.CreateChirpFlag(ctx, database.CreateChirpFlagParams{
+		ChirpID:      chirpID,
+		MatchedWords: result.Words(moderation.ActionFlag),
+	})
+	return err
+}
+
+// handlerGetModerationRules lists the active moderation rules, from the database and from the rules file.
+// It is registered as a handler for the "/admin/moderation/rules" endpoint with the GET method.
+func (c *apiConfig) handlerGetModerationRules(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	dbRules, err := c.db.GetModerationRules(req.Context())
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	rules := []moderationRuleResponse{}
+	for _, rule := range c.moderationFileRules {
+		rules = append(rules, moderationRuleResponse{
+			Word:   moderation.Normalize(rule.Word),
+			Action: rule.Action,
+			Source: "file",
+		})
+	}
+	for _, dbRule := range dbRules {
+		rules = append(rules, moderationRuleResponse{
+			Word:   dbRule.Word,
+			Action: moderation.Action(dbRule.Action),
+			Source: "database",
+		})
+	}
+
+	marshalOkJson(w, http.StatusOK, rules)
+}
+
+// handlerPutModerationRule creates or updates the moderation rule for the word in the path.
+// The body must contain the action: {"action": "censor" | "reject" | "flag"}.
+// The word is normalized (lowercased, punctuation removed) before being saved.
+// The new rule applies to the next chirps right away, without a restart.
+// It is registered as a handler for the "/admin/moderation/rules/{word}" endpoint with the PUT method.
+func (c *apiConfig) handlerPutModerationRule(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	word := moderation.Normalize(req.PathValue("word"))
+	if word == "" {
+		marshalError(w, http.StatusBadRequest, "Invalid word")
+		return
+	}
+
+	params := moderationRuleRequest{}
+	decoder := json.NewDecoder(req.Body)
+	if err := decoder.Decode(&params); err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	action, err := moderation.ParseAction(params.Action)
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rule, err := c.db.UpsertModerationRule(req.Context(), database.UpsertModerationRuleParams{
+		Word:   word,
+		Action: string(action),
+	})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := c.reloadModerationRules(req.Context()); err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	marshalOkJson(w, http.StatusOK, moderationRuleResponse{
+		Word:   rule.Word,
+		Action: moderation.Action(rule.Action),
+		Source: "database",
+	})
+}
+
+// handlerDeleteModerationRule deletes the database moderation rule for the word in the path.
+// Rules coming from the rules file cannot be deleted through the API.
+// It is registered as a handler for the "/admin/moderation/rules/{word}" endpoint with the DELETE method.
+func (c *apiConfig) handlerDeleteModerationRule(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	deleted, err := c.db.DeleteModerationRule(req.Context(), moderation.Normalize(req.PathValue("word")))
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if deleted == 0 {
+		marshalError(w, http.StatusNotFound, "Rule not found")
+		return
+	}
+
+	if err := c.reloadModerationRules(req.Context()); err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerGetChirpFlags lists the chirps flagged by a moderation rule that were not reviewed yet, oldest first.
+// It is registered as a handler for the "/admin/moderation/flags" endpoint with the GET method.
+func (c *apiConfig) handlerGetChirpFlags(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	dbFlags, err := c.db.GetOpenChirpFlags(req.Context())
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	flags := []chirpFlagResponse{}
+	for _, dbFlag := range dbFlags {
+		flags = append(flags, chirpFlagResponse{
+			ID:           dbFlag.ID,
+			ChirpID:      dbFlag.ChirpID,
+			MatchedWords: dbFlag.MatchedWords,
+			CreatedAt:    dbFlag.CreatedAt,
+		})
+	}
+
+	marshalOkJson(w, http.StatusOK, flags)
+}
+
+// handlerReviewChirpFlag marks a flag as reviewed, removing it from the open flags.
+// It is registered as a handler for the "/admin/moderation/flags/{flagID}/review" endpoint with the POST method.
+func (c *apiConfig) handlerReviewChirpFlag(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	flagID, err := uuid.Parse(req.PathValue("flagID"))
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid flag ID")
+		return
+	}
+
+	reviewed, err := c.db.ReviewChirpFlag(req.Context(), flagID)
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if reviewed == 0 {
+		marshalError(w, http.StatusNotFound, "Open flag not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// chirpCursor is the position of the last chirp returned in a page.
+// The next page starts right after (or before, for desc order) this chirp.
+type chirpCursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        uuid.UUID `json:"id"`
+}
+
+// encodeCursor marshals the cursor and encodes it as an opaque string
+// that is safe to use in a query string.
+func encodeCursor(cursor any) string {
+	dat, err := json.Marshal(cursor)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(dat)
+}
+
+// decodeCursor decodes a cursor produced by encodeCursor into cursor.
+func decodeCursor(s string, cursor any) error {
+	dat, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return errors.New("Invalid cursor")
+	}
+	if err := json.Unmarshal(dat, cursor); err != nil {
+		return errors.New("Invalid cursor")
+	}
+	return nil
+}
+
+// parsePageLimit reads the "limit" query parameter.
+// It defaults to defaultPageLimit and must be between 1 and maxPageLimit.
+func parsePageLimit(query url.Values) (int32, error) {
+	limitString := query.Get("limit")
+	if limitString == "" {
+		return defaultPageLimit, nil
+	}
+
+	limit, err := strconv.Atoi(limitString)
+	if err != nil || limit < 1 || limit > maxPageLimit {
+		return 0, errors.New("Invalid limit")
+	}
+
+	return int32(limit), nil
+}
+
+// parseChirpCursor reads the optional "cursor" query parameter.
+// It returns a nil cursor when the parameter is missing (first page).
+func parseChirpCursor(query url.Values) (*chirpCursor, error) {
+	cursorString := query.Get("cursor")
+	if cursorString == "" {
+		return nil, nil
+	}
+
+	cursor := &chirpCursor{}
+	if err := decodeCursor(cursorString, cursor); err != nil {
+		return nil, err
+	}
+	if cursor.ID == uuid.Nil || cursor.CreatedAt.IsZero() {
+		return nil, errors.New("Invalid cursor")
+	}
+
+	return cursor, nil
+}
+
+// queryParams converts the cursor to the nullable parameters used by the paginated queries.
+// A nil cursor (first page) gives invalid values so the queries skip the cursor condition.
+func (cursor *chirpCursor) queryParams() (sql.NullTime, uuid.NullUUID) {
+	if cursor == nil {
+		return sql.NullTime{}, uuid.NullUUID{}
+	}
+	return sql.NullTime{Time: cursor.CreatedAt, Valid: true}, uuid.NullUUID{UUID: cursor.ID, Valid: true}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/federicoReghini/Chirpy/internal/auth"
+	"github.com/federicoReghini/Chirpy/internal/database"
+	"github.com/federicoReghini/Chirpy/internal/lockout"
+	"github.com/federicoReghini/Chirpy/internal/mailer"
+	"github.com/google/uuid"
+)
+
+// passwordResetTokenDuration is how long a password reset token can be used.
+const passwordResetTokenDuration = time.Hour
+
+// passwordResetQueueSize is the number of password reset requests waiting to be sent,
+// the requests past it wait for a place.
+const passwordResetQueueSize = 100
+
+// Limits of the password reset requests, per email and per IP address, so an email cannot be
+// flooded and its last token kept invalidated, and a client cannot fill the queue.
+// The third request for an email locks it for 15 minutes, doubling with each further request.
+var (
+	passwordResetEmailPolicy = lockout.Policy{
+		Allowed:     3,
+		BaseLock:    15 * time.Minute,
+		MaxLock:     24 * time.Hour,
+		ForgetAfter: 24 * time.Hour,
+	}
+	passwordResetIPPolicy = lockout.Policy{
+		Allowed:     10,
+		BaseLock:    15 * time.Minute,
+		MaxLock:     24 * time.Hour,
+		ForgetAfter: 24 * time.Hour,
+	}
+)
+
+type passwordResetRequest struct {
+	Email string `json:"email"`
+}
+
+type passwordResetConfirmRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// handlerRequestPasswordReset queues a password reset email for the email of the body.
+// It responds 202 Accepted right away, whether the email belongs to a user or not:
+// the user is looked up and the token sent by runPasswordResetWorker, off the request,
+// so neither the response nor its timing tell who has an account.
+// The requests over the limits of an email or of an IP address are not queued,
+// and get the same 202 response.
+// It is registered as a handler for the "/api/password-reset" endpoint with the POST method.
+func (c *apiConfig) handlerRequestPasswordReset(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	params := passwordResetRequest{}
+	decoder := json.NewDecoder(req.Body)
+	if err := decoder.Decode(&params); err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	emailKey := strings.ToLower(strings.TrimSpace(params.Email))
+	ip := clientIP(req)
+	if c.passwordResetIPLimit.Attempt(ip) > 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if c.passwordResetEmailLimit.Attempt(emailKey) > 0 {
+		c.passwordResetIPLimit.Release(ip)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	select {
+	case c.passwordResets <- params.Email:
+	case <-req.Context().Done():
+		log.Printf("Password reset request canceled while waiting for the queue")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// runPasswordResetWorker sends the password reset emails queued by handlerRequestPasswordReset,
+// until ctx is done. Errors are logged, the requester is never told about them.
+func (c *apiConfig) runPasswordResetWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case email := <-c.passwordResets:
+			if err := c.sendPasswordResetEmail(ctx, email); err != nil {
+				log.Printf("Error sending password reset email: %s", err)
+			}
+		}
+	}
+}
+
+// sendPasswordResetEmail emails a password reset token to the user with the email, if there is one.
+// The tokens sent before stop working.
+func (c *apiConfig) sendPasswordResetEmail(ctx context.Context, email string) error {
+	user, err := c.db.GetUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := auth.MakeToken()
+	if err != nil {
+		return err
+	}
+
+	if err := c.db.DeletePasswordResetTokens(ctx, user.ID); err != nil {
+		return err
+	}
+
+	err = c.db.CreatePasswordResetToken(ctx, database.CreatePasswordResetTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(passwordResetTokenDuration),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = c.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your Chirpy account.\n\n"+
+			"Your password reset token is:\n%s\n\n"+
+			"Send it with your new password to POST %s/api/password-reset/confirm.\n"+
+			"The token expires in %d minutes. If you did not ask for a reset, ignore this email.\n",
+			token, c.baseURL, int(passwordResetTokenDuration.Minutes())),
+	})
+	if err != nil {
+		return fmt.Errorf("user %s: %w", user.ID, err)
+	}
+	return nil
+}
+
+// handlerConfirmPasswordReset sets a new password with a password reset token.
+// A token can only be used once. On success, every session of the user is logged out
+// and the other reset tokens of the user stop working.
+// It is registered as a handler for the "/api/password-reset/confirm" endpoint with the POST method.
+func (c *apiConfig) handlerConfirmPasswordReset(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	params := passwordResetConfirmRequest{}
+	decoder := json.NewDecoder(req.Body)
+	if err := decoder.Decode(&params); err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if params.Token == "" {
+		marshalError(w, http.StatusBadRequest, "Missing token")
+		return
+	}
+	if params.Password == "" {
+		marshalError(w, http.StatusBadRequest, "Password is required")
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(params.Password)
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tx, err := c.sqlDB.BeginTx(req.Context(), nil)
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := c.db.WithTx(tx)
+
+	resetToken, err := qtx.UsePasswordResetToken(req.Context(), auth.HashToken(params.Token))
+	if errors.Is(err, sql.ErrNoRows) {
+		marshalError(w, http.StatusBadRequest, "Invalid or expired token")
+		return
+	}
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	err = qtx.UpdateUserPassword(req.Context(), database.UpdateUserPasswordParams{
+		HashedPassword: hashedPassword,
+		ID:             resetToken.UserID,
+	})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := qtx.RevokeUserSessions(req.Context(), uuid.NullUUID{UUID: resetToken.UserID, Valid: true}); err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := qtx.DeletePasswordResetTokens(req.Context(), resetToken.UserID); err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
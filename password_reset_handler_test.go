@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/federicoReghini/Chirpy/internal/lockout"
+)
+
+func newPasswordResetTestConfig(queueSize int) *apiConfig {
+	return &apiConfig{
+		passwordResets:          make(chan string, queueSize),
+		passwordResetEmailLimit: lockout.NewTracker(passwordResetEmailPolicy),
+		passwordResetIPLimit:    lockout.NewTracker(passwordResetIPPolicy),
+	}
+}
+
+func postPasswordReset(t *testing.T, c *apiConfig, ctx context.Context, remoteAddr, body string) int {
+	t.Helper()
+	req := httptest.NewRequestWithContext(ctx, http.MethodPost, "/api/password-reset", strings.NewReader(body))
+	req.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	c.handlerRequestPasswordReset(w, req)
+	return w.Code
+}
+
+func TestHandlerRequestPasswordReset(t *testing.T) {
+	c := newPasswordResetTestConfig(1)
+	ctx := context.Background()
+
+	// The email is only queued, the response does not depend on it
+	if code := postPasswordReset(t, c, ctx, "10.0.0.1:1234", `{"email": "gopher@example.com"}`); code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", code)
+	}
+	if email := <-c.passwordResets; email != "gopher@example.com" {
+		t.Fatalf("Expected the email to be queued, got %q", email)
+	}
+
+	if code := postPasswordReset(t, c, ctx, "10.0.0.1:1234", `not json`); code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 for an invalid body, got %d", code)
+	}
+}
+
+func TestHandlerRequestPasswordReset_WaitsForTheQueue(t *testing.T) {
+	c := newPasswordResetTestConfig(1)
+	c.passwordResets <- "queued@example.com"
+
+	// A full queue is waited for, not skipped
+	done := make(chan int)
+	go func() {
+		done <- postPasswordReset(t, c, context.Background(), "10.0.0.1:1234", `{"email": "gopher@example.com"}`)
+	}()
+	if email := <-c.passwordResets; email != "queued@example.com" {
+		t.Fatalf("Expected the queued email first, got %q", email)
+	}
+	if code := <-done; code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", code)
+	}
+	if email := <-c.passwordResets; email != "gopher@example.com" {
+		t.Fatalf("Expected the email to be queued once there was room, got %q", email)
+	}
+}
+
+func TestHandlerRequestPasswordReset_Limits(t *testing.T) {
+	c := newPasswordResetTestConfig(100)
+	ctx := context.Background()
+
+	// The same email from several addresses: only the allowed requests are queued
+	for i := range passwordResetEmailPolicy.Allowed + 2 {
+		addr := fmt.Sprintf("10.0.0.%d:1234", i+1)
+		if code := postPasswordReset(t, c, ctx, addr, `{"email": " Gopher@Example.com"}`); code != http.StatusAccepted {
+			t.Fatalf("Expected 202 for request %d, got %d", i+1, code)
+		}
+	}
+	if len(c.passwordResets) != passwordResetEmailPolicy.Allowed {
+		t.Fatalf("Expected %d requests queued for the email, got %d", passwordResetEmailPolicy.Allowed, len(c.passwordResets))
+	}
+
+	// Other emails from one address: the address is limited, other users are not
+	for len(c.passwordResets) > 0 {
+		<-c.passwordResets
+	}
+	for i := range passwordResetIPPolicy.Allowed + 5 {
+		email := fmt.Sprintf(`{"email": "user%d@example.com"}`, i)
+		if code := postPasswordReset(t, c, ctx, "10.0.1.1:1234", email); code != http.StatusAccepted {
+			t.Fatalf("Expected 202 for request %d, got %d", i+1, code)
+		}
+	}
+	if len(c.passwordResets) != passwordResetIPPolicy.Allowed {
+		t.Fatalf("Expected %d requests queued for the address, got %d", passwordResetIPPolicy.Allowed, len(c.passwordResets))
+	}
+
+	postPasswordReset(t, c, ctx, "10.0.2.1:1234", `{"email": "other@example.com"}`)
+	if len(c.passwordResets) != passwordResetIPPolicy.Allowed+1 {
+		t.Fatal("Expected the request of another address to be queued")
+	}
+}
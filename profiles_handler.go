@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/federicoReghini/Chirpy/internal/auth"
+	"github.com/google/uuid"
+)
+
+// Limits of the profile fields
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+	maxAvatarURLLength   = 2048
+)
+
+var usernameRegexp = regexp.MustCompile(`^[a-z0-9_]{3,30}$`)
+
+// reservedUsernames cannot be taken: "/api/users/by-username/{handle}" shares its pattern
+// with the GET routes of "/api/users/{userID}/", which win for these handles.
+var reservedUsernames = map[string]bool{
+	"mentions":  true,
+	"followers": true,
+	"following": true,
+}
+
+// invalidUsernameMessage is the error of the requests with an invalid username.
+const invalidUsernameMessage = "Invalid username, it must be 3 to 30 letters, digits or underscores, and not a reserved word"
+
+// normalizeUsername lowercases a username and removes the leading '@' of a handle.
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(username), "@"))
+}
+
+// validUsername reports whether a normalized username is 3 to 30 letters, digits or underscores,
+// and is not reserved.
+func validUsername(username string) bool {
+	return usernameRegexp.MatchString(username) && !reservedUsernames[username]
+}
+
+// generateUsername returns a random username, for the users signing up without one.
+func generateUsername() (string, error) {
+	token, err := auth.MakeToken()
+	if err != nil {
+		return "", err
+	}
+	return "user_" + token[:12], nil
+}
+
+// validAvatarURL reports whether s is empty, to remove the avatar, or an absolute http(s) URL.
+func validAvatarURL(s string) bool {
+	if s == "" {
+		return true
+	}
+	if len(s) > maxAvatarURLLength {
+		return false
+	}
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// validateProfile checks the profile fields of a user update, the nil fields are not changed.
+// It returns the message of the 400 response, or "" when the fields are valid.
+func validateProfile(username, displayName, bio, avatarURL *string) string {
+	if username != nil && !validUsername(*username) {
+		return invalidUsernameMessage
+	}
+	if displayName != nil && utf8.RuneCountInString(*displayName) > maxDisplayNameLength {
+		return "Display name is too long"
+	}
+	if bio != nil && utf8.RuneCountInString(*bio) > maxBioLength {
+		return "Bio is too long"
+	}
+	if avatarURL != nil && !validAvatarURL(*avatarURL) {
+		return "Invalid avatar URL"
+	}
+	return ""
+}
+
+// usernameTaken reports whether the username belongs to a user other than userID.
+func (c *apiConfig) usernameTaken(ctx context.Context, username string, userID uuid.UUID) (bool, error) {
+	user, err := c.db.GetUserByUsername(ctx, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return user.ID != userID, nil
+}
+
+// handlerGetUser returns the public profile of the user in the path.
+// It is registered as a handler for the "/api/users/{userID}" endpoint with the GET method.
+func (c *apiConfig) handlerGetUser(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	user, err := c.db.GetUserByID(req.Context(), userID)
+	if err != nil {
+		marshalError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	marshalOkJson(w, http.StatusOK, newPublicUserResponse(user))
+}
+
+// handlerGetUserByUsername returns the public profile of the user with the handle in the path.
+// The handle is case-insensitive and may start with '@'.
+// It is registered as a handler for the "/api/users/by-username/{handle}" endpoint with the GET method,
+// through the "/api/users/{segment}/{handle}" pattern. The handles of reservedUsernames never get here.
+func (c *apiConfig) handlerGetUserByUsername(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	if req.PathValue("segment") != "by-username" {
+		marshalError(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	user, err := c.db.GetUserByUsername(req.Context(), normalizeUsername(req.PathValue("handle")))
+	if err != nil {
+		marshalError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	marshalOkJson(w, http.StatusOK, newPublicUserResponse(user))
+}
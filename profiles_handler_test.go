@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidUsername(t *testing.T) {
+	tests := []struct {
+		username string
+		want     bool
+	}{
+		{"gopher", true},
+		{"go_pher_42", true},
+		{"go", false},
+		{"Gopher", false},
+		{"go-pher", false},
+		{strings.Repeat("a", 31), false},
+		{"mentions", false},
+		{"followers", false},
+		{"following", false},
+	}
+
+	for _, tt := range tests {
+		if got := validUsername(tt.username); got != tt.want {
+			t.Errorf("validUsername(%q) = %v, want %v", tt.username, got, tt.want)
+		}
+	}
+}
+
+// TestReservedUsernames_Routes checks that "/api/users/by-username/{handle}" reaches
+// handlerGetUserByUsername for the valid usernames only, the reserved ones go to other routes.
+func TestReservedUsernames_Routes(t *testing.T) {
+	c := &apiConfig{}
+	mux := http.NewServeMux()
+	c.registerRoutes(mux)
+
+	route := func(username string) string {
+		_, pattern := mux.Handler(httptest.NewRequest(http.MethodGet, "/api/users/by-username/"+username, nil))
+		return pattern
+	}
+	byUsernamePattern := route("gopher")
+	if !strings.HasSuffix(byUsernamePattern, "/api/users/{segment}/{handle}") {
+		t.Fatalf("Expected a valid username to be routed to the by-username pattern, got %s", byUsernamePattern)
+	}
+
+	for username := range reservedUsernames {
+		if pattern := route(username); pattern == byUsernamePattern {
+			t.Errorf("Expected the reserved username %q to be routed elsewhere, got %s", username, pattern)
+		}
+	}
+	for _, username := range []string{"follow", "role", "revisions"} {
+		if pattern := route(username); pattern != byUsernamePattern {
+			t.Errorf("Username %q is routed to %s, add it to reservedUsernames", username, pattern)
+		}
+	}
+}
+
+func TestHandlerGetUserByUsername_OtherSegment(t *testing.T) {
+	c := &apiConfig{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/users/{segment}/{handle}", c.handlerGetUserByUsername)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/users/by-email/gopher", nil))
+
+	var resp myError
+	if w.Code != http.StatusNotFound || json.Unmarshal(w.Body.Bytes(), &resp) != nil || resp.Error == "" {
+		t.Fatalf("Expected a 404 JSON error, got %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/federicoReghini/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// originalChirpID returns the ID to reference when rechirping, quoting or replying to chirp.
+// A rechirp has no content of its own, so references always point to the chirp it reposts.
+func originalChirpID(chirp database.Chirp) uuid.NullUUID {
+	if chirp.Kind == "rechirp" && chirp.RechirpOf.Valid {
+		return chirp.RechirpOf
+	}
+	return uuid.NullUUID{UUID: chirp.ID, Valid: true}
+}
+
+// handlerRechirp reposts the chirp in the path on behalf of the authenticated user.
+// The rechirp is a chirp of kind "rechirp" with an empty body and rechirp_of set
+// to the original chirp, so it shows up in the feed of the user who rechirped it.
+// Rechirping a rechirp reposts the original chirp.
+// It returns 201 with the rechirp, 404 if the chirp does not exist
+// and 409 if the user already rechirped it.
+// It is registered as a handler for the "/chirps/{chirpID}/rechirp" endpoint with the POST method.
+func (c *apiConfig) handlerRechirp(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	userID := userIDFromContext(req.Context())
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	chirp, err := c.db.GetChirp(req.Context(), chirpID)
+	if err != nil {
+		marshalError(w, http.StatusNotFound, "Chirp not found")
+		return
+	}
+
+	dbRechirp, err := c.db.CreateRechirp(req.Context(), database.CreateRechirpParams{
+		UserID:    uuid.NullUUID{UUID: userID, Valid: true},
+		RechirpOf: originalChirpID(chirp),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		marshalError(w, http.StatusConflict, "Chirp already rechirped")
+		return
+	}
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	rechirp, err := c.newChirpResponse(req.Context(), dbRechirp, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	marshalOkJson(w, http.StatusCreated, rechirp)
+}
+
+// handlerUndoRechirp removes the rechirp of the chirp in the path made by the authenticated user.
+// It returns 204 No Content on success and 404 if the user did not rechirp the chirp.
+// It is registered as a handler for the "/chirps/{chirpID}/rechirp" endpoint with the DELETE method.
+func (c *apiConfig) handlerUndoRechirp(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	userID := userIDFromContext(req.Context())
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	chirp, err := c.db.GetChirp(req.Context(), chirpID)
+	if err != nil {
+		marshalError(w, http.StatusNotFound, "Chirp not found")
+		return
+	}
+
+	deleted, err := c.db.DeleteRechirp(req.Context(), database.DeleteRechirpParams{
+		UserID:    uuid.NullUUID{UUID: userID, Valid: true},
+		RechirpOf: originalChirpID(chirp),
+	})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if deleted == 0 {
+		marshalError(w, http.StatusNotFound, "Rechirp not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
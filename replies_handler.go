@@ -0,0 +1,144 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/federicoReghini/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// chirpThreadNode is a chirp with its replies, used to return a conversation as a tree.
+type chirpThreadNode struct {
+	chirpResponse
+	Replies []*chirpThreadNode `json:"replies"`
+}
+
+// conversationResponse is the full conversation around a chirp.
+// Ancestors goes from the root of the thread down to the direct parent of the chirp,
+// Chirp is the requested chirp with all its descendants nested as replies.
+type conversationResponse struct {
+	Ancestors []chirpResponse  `json:"ancestors"`
+	Chirp     *chirpThreadNode `json:"chirp"`
+}
+
+// handlerGetChirpReplies returns the direct replies to a chirp, oldest first.
+// It accepts the same limit and cursor query parameters as handlerGetChips
+// and responds with the same paginated envelope.
+// It is registered as a handler for the "/chirps/{chirpID}/replies" endpoint with the GET method.
+func (c *apiConfig) handlerGetChirpReplies(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	if _, err := c.db.GetChirp(req.Context(), chirpID); err != nil {
+		marshalError(w, http.StatusNotFound, "Chirp not found")
+		return
+	}
+
+	query := req.URL.Query()
+
+	limit, err := parsePageLimit(query)
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cursor, err := parseChirpCursor(query)
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	cursorCreatedAt, cursorID := cursor.queryParams()
+
+	dbChirps, err := c.db.GetChirpReplies(req.Context(), database.GetChirpRepliesParams{
+		ChirpID:         uuid.NullUUID{UUID: chirpID, Valid: true},
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageLimit:       limit + 1,
+	})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	page, err := c.newChirpsPage(req.Context(), dbChirps, limit, optionalUserIDFromContext(req.Context()))
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	marshalOkJson(w, http.StatusOK, page)
+}
+
+// handlerGetChirpConversation returns the conversation a chirp belongs to:
+// the chain of chirps it replies to, up to the root of the thread,
+// and the tree of all the replies below it.
+// It is registered as a handler for the "/chirps/{chirpID}/conversation" endpoint with the GET method.
+func (c *apiConfig) handlerGetChirpConversation(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	dbChirp, err := c.db.GetChirp(req.Context(), chirpID)
+	if err != nil {
+		marshalError(w, http.StatusNotFound, "Chirp not found")
+		return
+	}
+
+	ancestors, err := c.db.GetChirpAncestors(req.Context(), chirpID)
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	descendants, err := c.db.GetChirpDescendants(req.Context(), uuid.NullUUID{UUID: chirpID, Valid: true})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Decorate the whole conversation at once, then split it back
+	dbChirps := make([]database.Chirp, 0, len(ancestors)+1+len(descendants))
+	dbChirps = append(dbChirps, ancestors...)
+	dbChirps = append(dbChirps, dbChirp)
+	dbChirps = append(dbChirps, descendants...)
+
+	chirps, err := c.newChirpResponses(req.Context(), dbChirps, optionalUserIDFromContext(req.Context()))
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	root := &chirpThreadNode{
+		chirpResponse: chirps[len(ancestors)],
+		Replies:       []*chirpThreadNode{},
+	}
+
+	// Descendants are sorted by creation time, so a parent is always seen before its replies
+	nodes := map[uuid.UUID]*chirpThreadNode{root.ID: root}
+	for _, chirp := range chirps[len(ancestors)+1:] {
+		parent, ok := nodes[chirp.InReplyTo.UUID]
+		if !ok {
+			continue
+		}
+		node := &chirpThreadNode{
+			chirpResponse: chirp,
+			Replies:       []*chirpThreadNode{},
+		}
+		parent.Replies = append(parent.Replies, node)
+		nodes[node.ID] = node
+	}
+
+	marshalOkJson(w, http.StatusOK, conversationResponse{
+		Ancestors: chirps[:len(ancestors)],
+		Chirp:     root,
+	})
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/federicoReghini/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const maxReportDetailsLength = 500
+
+// reportReasons are the reason codes accepted when reporting a chirp
+var reportReasons = map[string]bool{
+	"spam":           true,
+	"harassment":     true,
+	"hate":           true,
+	"violence":       true,
+	"misinformation": true,
+	"other":          true,
+}
+
+// reportResolutions maps the actions a moderator can take on a report
+// to the resolution saved on the reports of the chirp
+var reportResolutions = map[string]string{
+	"hide":    "hidden",
+	"remove":  "removed",
+	"dismiss": "dismissed",
+}
+
+type createReportRequest struct {
+	Reason  string `json:"reason"`
+	Details string `json:"details"`
+}
+
+type reportResponse struct {
+	ID        uuid.UUID     `json:"id"`
+	ChirpID   uuid.NullUUID `json:"chirp_id"`
+	Reason    string        `json:"reason"`
+	Details   string        `json:"details"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// openReportResponse is a report in the moderator queue, with the reported chirp
+type openReportResponse struct {
+	reportResponse
+	ReporterID    uuid.UUID     `json:"reporter_id"`
+	ChirpBody     string        `json:"chirp_body"`
+	ChirpUserID   uuid.NullUUID `json:"chirp_user_id"`
+	ChirpHiddenAt *time.Time    `json:"chirp_hidden_at"`
+}
+
+type openReportsPage struct {
+	Reports    []openReportResponse `json:"reports"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+	HasMore    bool                 `json:"has_more"`
+}
+
+type resolveReportRequest struct {
+	Action string `json:"action"`
+	Note   string `json:"note"`
+}
+
+type moderationActionResponse struct {
+	ID          uuid.UUID     `json:"id"`
+	ModeratorID uuid.NullUUID `json:"moderator_id"`
+	Action      string        `json:"action"`
+	ChirpID     uuid.UUID     `json:"chirp_id"`
+	ReportID    uuid.NullUUID `json:"report_id"`
+	Note        string        `json:"note"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+type moderationActionsPage struct {
+	Actions    []moderationActionResponse `json:"actions"`
+	NextCursor string                     `json:"next_cursor,omitempty"`
+	HasMore    bool                       `json:"has_more"`
+}
+
+func newModerationActionResponse(dbAction database.ModerationAction) moderationActionResponse {
+	return moderationActionResponse{
+		ID:          dbAction.ID,
+		ModeratorID: dbAction.ModeratorID,
+		Action:      dbAction.Action,
+		ChirpID:     dbAction.ChirpID,
+		ReportID:    dbAction.ReportID,
+		Note:        dbAction.Note,
+		CreatedAt:   dbAction.CreatedAt,
+	}
+}
+
+// handlerCreateReport reports the chirp in the path for the moderators to review.
+// The body must contain a reason code (spam, harassment, hate, violence, misinformation or other)
+// and can contain free-text details of at most 500 characters.
+// A user can have only one open report per chirp, reporting it again returns 409 Conflict.
+// It is registered as a handler for the "/chirps/{chirpID}/reports" endpoint with the POST method.
+func (c *apiConfig) handlerCreateReport(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	userID := userIDFromContext(req.Context())
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	params := createReportRequest{}
+	decoder := json.NewDecoder(req.Body)
+	if err := decoder.Decode(&params); err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if !reportReasons[params.Reason] {
+		marshalError(w, http.StatusBadRequest, "Invalid reason")
+		return
+	}
+
+	if len(params.Details) > maxReportDetailsLength {
+		marshalError(w, http.StatusBadRequest, "Details are too long")
+		return
+	}
+
+	if _, err := c.db.GetChirp(req.Context(), chirpID); err != nil {
+		marshalError(w, http.StatusNotFound, "Chirp not found")
+		return
+	}
+
+	dbReport, err := c.db.CreateReport(req.Context(), database.CreateReportParams{
+		ChirpID:    uuid.NullUUID{UUID: chirpID, Valid: true},
+		ReporterID: userID,
+		Reason:     params.Reason,
+		Details:    params.Details,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		marshalError(w, http.StatusConflict, "Chirp already reported")
+		return
+	}
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	marshalOkJson(w, http.StatusCreated, reportResponse{
+		ID:        dbReport.ID,
+		ChirpID:   dbReport.ChirpID,
+		Reason:    dbReport.Reason,
+		Details:   dbReport.Details,
+		CreatedAt: dbReport.CreatedAt,
+	})
+}
+
+// handlerGetOpenReports returns the queue of reports not resolved yet, oldest first.
+// It accepts the same limit and cursor query parameters as handlerGetChips.
+// It is registered as a handler for the "/admin/reports" endpoint with the GET method.
+func (c *apiConfig) handlerGetOpenReports(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	query := req.URL.Query()
+
+	limit, err := parsePageLimit(query)
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cursor, err := parseChirpCursor(query)
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	cursorCreatedAt, cursorID := cursor.queryParams()
+
+	rows, err := c.db.GetOpenReports(req.Context(), database.GetOpenReportsParams{
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageLimit:       limit + 1,
+	})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	page := openReportsPage{
+		Reports: []openReportResponse{},
+		HasMore: len(rows) > int(limit),
+	}
+	if page.HasMore {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		page.NextCursor = encodeCursor(chirpCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	for _, row := range rows {
+		report := openReportResponse{
+			reportResponse: reportResponse{
+				ID:        row.ID,
+				ChirpID:   row.ChirpID,
+				Reason:    row.Reason,
+				Details:   row.Details,
+				CreatedAt: row.CreatedAt,
+			},
+			ReporterID:  row.ReporterID,
+			ChirpBody:   row.ChirpBody,
+			ChirpUserID: row.ChirpUserID,
+		}
+		if row.ChirpHiddenAt.Valid {
+			report.ChirpHiddenAt = &row.ChirpHiddenAt.Time
+		}
+		page.Reports = append(page.Reports, report)
+	}
+
+	marshalOkJson(w, http.StatusOK, page)
+}
+
+// handlerResolveReport resolves the report in the path by acting on the reported chirp.
+// The body must contain the action and can contain a note for the audit trail:
+//   - hide: the chirp is hidden from every public endpoint, it can be restored later
+//   - remove: the chirp is deleted
+//   - dismiss: the chirp is left as it is
+//
+// Every open report of the same chirp is resolved together, and the action is
+// recorded in the audit trail with the moderator who took it.
+// It is registered as a handler for the "/admin/reports/{reportID}/resolve" endpoint with the POST method.
+func (c *apiConfig) handlerResolveReport(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	moderatorID := userIDFromContext(req.Context())
+
+	reportID, err := uuid.Parse(req.PathValue("reportID"))
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid report ID")
+		return
+	}
+
+	params := resolveReportRequest{}
+	decoder := json.NewDecoder(req.Body)
+	if err := decoder.Decode(&params); err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	resolution, ok := reportResolutions[params.Action]
+	if !ok {
+		marshalError(w, http.StatusBadRequest, "Invalid action")
+		return
+	}
+
+	// The chirp, its reports and the audit trail are updated together
+	tx, err := c.sqlDB.BeginTx(req.Context(), nil)
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := c.db.WithTx(tx)
+
+	dbReport, err := qtx.GetReport(req.Context(), reportID)
+	if err != nil {
+		marshalError(w, http.StatusNotFound, "Report not found")
+		return
+	}
+	if dbReport.ResolvedAt.Valid || !dbReport.ChirpID.Valid {
+		marshalError(w, http.StatusConflict, "Report already resolved")
+		return
+	}
+
+	// Reports are resolved before removing the chirp, which sets their chirp_id to null
+	resolved, err := qtx.ResolveChirpReports(req.Context(), database.ResolveChirpReportsParams{
+		ModeratorID: uuid.NullUUID{UUID: moderatorID, Valid: true},
+		Resolution:  sql.NullString{String: resolution, Valid: true},
+		ChirpID:     dbReport.ChirpID,
+	})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if resolved == 0 {
+		marshalError(w, http.StatusConflict, "Report already resolved")
+		return
+	}
+
+	var mediaKeys []string
+	switch params.Action {
+	case "hide":
+		_, err = qtx.SetChirpHidden(req.Context(), database.SetChirpHiddenParams{
+			Hidden: true,
+			ID:     dbReport.ChirpID.UUID,
+		})
+	case "remove":
+		// The reports of the rechirps removed with the chirp are closed too
+		if _, err = qtx.ResolveDeletedChirpReports(req.Context(), dbReport.ChirpID); err != nil {
+			break
+		}
+		if mediaKeys, err = deleteChirpMedia(req.Context(), qtx, dbReport.ChirpID.UUID); err != nil {
+			break
+		}
+		_, err = qtx.RemoveChirp(req.Context(), dbReport.ChirpID.UUID)
+	}
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	dbAction, err := qtx.CreateModerationAction(req.Context(), database.CreateModerationActionParams{
+		ModeratorID: uuid.NullUUID{UUID: moderatorID, Valid: true},
+		Action:      params.Action,
+		ChirpID:     dbReport.ChirpID.UUID,
+		ReportID:    uuid.NullUUID{UUID: dbReport.ID, Valid: true},
+		Note:        params.Note,
+	})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.deleteMediaFiles(mediaKeys...)
+
+	marshalOkJson(w, http.StatusOK, newModerationActionResponse(dbAction))
+}
+
+// handlerUnhideChirp makes a chirp hidden by a moderator public again.
+// The optional body can contain a note for the audit trail: {"note": "..."}.
+// It is registered as a handler for the "/admin/chirps/{chirpID}/unhide" endpoint with the POST method.
+func (c *apiConfig) handlerUnhideChirp(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	moderatorID := userIDFromContext(req.Context())
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	params := resolveReportRequest{}
+	if req.ContentLength != 0 {
+		decoder := json.NewDecoder(req.Body)
+		if err := decoder.Decode(&params); err != nil {
+			marshalError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	dbChirp, err := c.db.GetChirpForModeration(req.Context(), chirpID)
+	if err != nil {
+		marshalError(w, http.StatusNotFound, "Chirp not found")
+		return
+	}
+	if !dbChirp.HiddenAt.Valid {
+		marshalError(w, http.StatusConflict, "Chirp is not hidden")
+		return
+	}
+
+	tx, err := c.sqlDB.BeginTx(req.Context(), nil)
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := c.db.WithTx(tx)
+
+	_, err = qtx.SetChirpHidden(req.Context(), database.SetChirpHiddenParams{
+		Hidden: false,
+		ID:     chirpID,
+	})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	dbAction, err := qtx.CreateModerationAction(req.Context(), database.CreateModerationActionParams{
+		ModeratorID: uuid.NullUUID{UUID: moderatorID, Valid: true},
+		Action:      "unhide",
+		ChirpID:     chirpID,
+		Note:        params.Note,
+	})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	marshalOkJson(w, http.StatusOK, newModerationActionResponse(dbAction))
+}
+
+// handlerGetModerationActions returns the audit trail of the moderator actions, newest first.
+// The optional chirp_id query parameter restricts it to the actions on one chirp.
+// It accepts the same limit and cursor query parameters as handlerGetChips.
+// It is registered as a handler for the "/admin/moderation/actions" endpoint with the GET method.
+func (c *apiConfig) handlerGetModerationActions(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	query := req.URL.Query()
+
+	chirpID := uuid.NullUUID{}
+	if chirpIDString := query.Get("chirp_id"); chirpIDString != "" {
+		parsedID, err := uuid.Parse(chirpIDString)
+		if err != nil {
+			marshalError(w, http.StatusBadRequest, "Invalid chirp ID")
+			return
+		}
+		chirpID = uuid.NullUUID{UUID: parsedID, Valid: true}
+	}
+
+	limit, err := parsePageLimit(query)
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cursor, err := parseChirpCursor(query)
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	cursorCreatedAt, cursorID := cursor.queryParams()
+
+	dbActions, err := c.db.GetModerationActions(req.Context(), database.GetModerationActionsParams{
+		ChirpID:         chirpID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageLimit:       limit + 1,
+	})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	page := moderationActionsPage{
+		Actions: []moderationActionResponse{},
+		HasMore: len(dbActions) > int(limit),
+	}
+	if page.HasMore {
+		dbActions = dbActions[:limit]
+		last := dbActions[len(dbActions)-1]
+		page.NextCursor = encodeCursor(chirpCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	for _, dbAction := range dbActions {
+		page.Actions = append(page.Actions, newModerationActionResponse(dbAction))
+	}
+
+	marshalOkJson(w, http.StatusOK, page)
+}
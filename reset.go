@@ -2,6 +2,9 @@ package main
 
 import "net/http"
 
+// handlerReset deletes all the users.
+// On top of the admin role required for every /admin/ endpoint,
+// it is only enabled on the dev platform.
 func (c *apiConfig) handlerReset(w http.ResponseWriter, r *http.Request) {
 	if c.platform == "dev" {
 		c.db.DeleteUsers(r.Context())
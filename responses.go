@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/federicoReghini/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// The types of this file are the JSON bodies of the responses.
+// The handlers never marshal the rows of the database package directly:
+// every field sent to the client is listed here, so the password hash, the token hashes
+// and the columns added later stay on the server.
+
+// userResponse is the account of the authenticated user, with its private fields such as the email.
+type userResponse struct {
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	Username      string    `json:"username"`
+	DisplayName   string    `json:"display_name"`
+	Bio           string    `json:"bio"`
+	AvatarURL     string    `json:"avatar_url"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
+	Role          string    `json:"role"`
+}
+
+func newUserResponse(user database.User) userResponse {
+	return userResponse{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		Username:      user.Username,
+		DisplayName:   user.DisplayName,
+		Bio:           user.Bio,
+		AvatarURL:     user.AvatarUrl,
+		IsChirpyRed:   user.IsChirpyRed,
+		Role:          user.Role,
+	}
+}
+
+// publicUserResponse is the representation of a user visible to everyone.
+// It must never contain the email or the password of the user.
+type publicUserResponse struct {
+	ID          uuid.UUID `json:"id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func newPublicUserResponse(user database.User) publicUserResponse {
+	return publicUserResponse{
+		ID:          user.ID,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarURL:   user.AvatarUrl,
+		IsChirpyRed: user.IsChirpyRed,
+		CreatedAt:   user.CreatedAt,
+	}
+}
+
+// loginResponse is the account of the user who logged in and the tokens of the new session.
+type loginResponse struct {
+	userResponse
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// tokenResponse is the pair of tokens returned by a refresh.
+type tokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// chirpResponse is a chirp as returned by the API, with its like counters.
+// It lists the fields explicitly so internal columns such as the search vector never reach the client.
+// LikedByMe is only set when the request carries a valid bearer token.
+type chirpResponse struct {
+	ID        uuid.UUID       `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	Body      string          `json:"body"`
+	UserID    uuid.NullUUID   `json:"user_id"`
+	InReplyTo uuid.NullUUID   `json:"in_reply_to"`
+	Kind      string          `json:"kind"`
+	RechirpOf uuid.NullUUID   `json:"rechirp_of"`
+	QuoteOf   uuid.NullUUID   `json:"quote_of"`
+	LikeCount int64           `json:"like_count"`
+	LikedByMe *bool           `json:"liked_by_me,omitempty"`
+	Media     []mediaResponse `json:"media,omitempty"`
+}
+
+// newChirpResponses decorates the chirps with their like count, their media and,
+// when viewerID is valid, whether the viewer liked them.
+// The counters and media of the whole slice are loaded with three queries.
+func (c *apiConfig) newChirpResponses(ctx context.Context, dbChirps []database.Chirp, viewerID uuid.NullUUID) ([]chirpResponse, error) {
+	chirps := []chirpResponse{}
+	if len(dbChirps) == 0 {
+		return chirps, nil
+	}
+
+	chirpIDs := make([]uuid.UUID, 0, len(dbChirps))
+	for _, dbChirp := range dbChirps {
+		chirpIDs = append(chirpIDs, dbChirp.ID)
+	}
+
+	likeCounts, err := c.db.CountChirpLikes(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+	likeCountByChirp := map[uuid.UUID]int64{}
+	for _, likeCount := range likeCounts {
+		likeCountByChirp[likeCount.ChirpID] = likeCount.LikeCount
+	}
+
+	mediaByChirp, err := c.getChirpsMedia(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var likedByViewer map[uuid.UUID]bool
+	if viewerID.Valid {
+		likedIDs, err := c.db.GetLikedChirpIDs(ctx, database.GetLikedChirpIDsParams{
+			UserID:   viewerID.UUID,
+			ChirpIds: chirpIDs,
+		})
+		if err != nil {
+			return nil, err
+		}
+		likedByViewer = map[uuid.UUID]bool{}
+		for _, likedID := range likedIDs {
+			likedByViewer[likedID] = true
+		}
+	}
+
+	for _, dbChirp := range dbChirps {
+		chirp := chirpResponse{
+			ID:        dbChirp.ID,
+			CreatedAt: dbChirp.CreatedAt,
+			UpdatedAt: dbChirp.UpdatedAt,
+			Body:      dbChirp.Body,
+			UserID:    dbChirp.UserID,
+			InReplyTo: dbChirp.InReplyTo,
+			Kind:      dbChirp.Kind,
+			RechirpOf: dbChirp.RechirpOf,
+			QuoteOf:   dbChirp.QuoteOf,
+			LikeCount: likeCountByChirp[dbChirp.ID],
+			Media:     mediaByChirp[dbChirp.ID],
+		}
+		if likedByViewer != nil {
+			likedByMe := likedByViewer[dbChirp.ID]
+			chirp.LikedByMe = &likedByMe
+		}
+		chirps = append(chirps, chirp)
+	}
+
+	return chirps, nil
+}
+
+// newChirpResponse is newChirpResponses for a single chirp.
+func (c *apiConfig) newChirpResponse(ctx context.Context, dbChirp database.Chirp, viewerID uuid.NullUUID) (chirpResponse, error) {
+	chirps, err := c.newChirpResponses(ctx, []database.Chirp{dbChirp}, viewerID)
+	if err != nil {
+		return chirpResponse{}, err
+	}
+	return chirps[0], nil
+}
+
+// chirpRevisionResponse is a previous body of an edited chirp.
+type chirpRevisionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	ChirpID    uuid.UUID `json:"chirp_id"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+func newChirpRevisionResponse(revision database.ChirpRevision) chirpRevisionResponse {
+	return chirpRevisionResponse{
+		ID:         revision.ID,
+		ChirpID:    revision.ChirpID,
+		Body:       revision.Body,
+		CreatedAt:  revision.CreatedAt,
+		ReplacedAt: revision.ReplacedAt,
+	}
+}
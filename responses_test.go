@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/federicoReghini/Chirpy/internal/auth"
+	"github.com/federicoReghini/Chirpy/internal/database"
+	"github.com/federicoReghini/Chirpy/internal/lockout"
+	"github.com/federicoReghini/Chirpy/internal/mailer"
+	"github.com/google/uuid"
+)
+
+const (
+	testPasswordHash = "$2a$10$0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOP"
+	testTokenHash    = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+)
+
+// sensitiveKeys must not appear in any response body.
+var sensitiveKeys = []string{"hashed_password", "token_hash", "password"}
+
+func testUser() database.User {
+	return database.User{
+		ID:              uuid.New(),
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
+		Email:           "gopher@example.com",
+		HashedPassword:  testPasswordHash,
+		IsChirpyRed:     true,
+		Role:            "user",
+		EmailVerifiedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		Username:        "gopher",
+		DisplayName:     "Gopher",
+		Bio:             "Digging",
+		AvatarUrl:       "https://example.com/gopher.png",
+	}
+}
+
+func assertNoSecrets(t *testing.T, name string, v any) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("%s: error marshaling: %v", name, err)
+	}
+
+	body := string(data)
+	for _, secret := range []string{testPasswordHash, testTokenHash} {
+		if strings.Contains(body, secret) {
+			t.Errorf("%s: expected no secret in %s", name, body)
+		}
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("%s: error unmarshaling: %v", name, err)
+	}
+	for _, key := range sensitiveKeys {
+		if _, ok := fields[key]; ok {
+			t.Errorf("%s: expected no %q field in %s", name, key, body)
+		}
+	}
+}
+
+func TestResponses_NoSecrets(t *testing.T) {
+	user := testUser()
+
+	tests := []struct {
+		name string
+		v    any
+	}{
+		{"userResponse", newUserResponse(user)},
+		{"publicUserResponse", newPublicUserResponse(user)},
+		{"loginResponse", loginResponse{userResponse: newUserResponse(user), Token: "jwt", RefreshToken: "refresh"}},
+		{"tokenResponse", tokenResponse{Token: "jwt", RefreshToken: "refresh"}},
+		// The rows themselves hide their secrets too, in case one is marshaled by mistake
+		{"database.User", user},
+		{"database.RefreshToken", database.RefreshToken{TokenHash: testTokenHash}},
+		{"database.EmailVerificationToken", database.EmailVerificationToken{TokenHash: testTokenHash}},
+		{"database.PasswordResetToken", database.PasswordResetToken{TokenHash: testTokenHash}},
+	}
+
+	for _, tt := range tests {
+		assertNoSecrets(t, tt.name, tt.v)
+	}
+}
+
+func TestUserResponse(t *testing.T) {
+	user := testUser()
+
+	got := newUserResponse(user)
+	if got.Email != user.Email || !got.EmailVerified || got.Username != user.Username || got.AvatarURL != user.AvatarUrl {
+		t.Fatalf("Expected the fields of the user, got %+v", got)
+	}
+
+	user.EmailVerifiedAt = sql.NullTime{}
+	if newUserResponse(user).EmailVerified {
+		t.Fatal("Expected email_verified to be false")
+	}
+}
+
+func TestPublicUserResponse_NoEmail(t *testing.T) {
+	data, err := json.Marshal(newPublicUserResponse(testUser()))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if strings.Contains(string(data), "gopher@example.com") || strings.Contains(string(data), `"email"`) {
+		t.Fatalf("Expected no email in the public profile, got %s", data)
+	}
+}
+
+// handlerTestPassword is the password of the user of the handler tests.
+const handlerTestPassword = "correct horse battery staple"
+
+type testMailer struct{}
+
+func (testMailer) Send(context.Context, mailer.Message) error { return nil }
+
+// newHandlerTestConfig returns an apiConfig on a fake database holding a user
+// whose password is handlerTestPassword.
+func newHandlerTestConfig(t *testing.T) (*apiConfig, *fakeDB, database.User) {
+	t.Helper()
+	f, db, q := newFakeDB()
+
+	keys := auth.NewHMACKeySet("test-secret")
+	validator, err := auth.NewValidator(keys, auth.ValidatorConfig{Audience: defaultJWTAudience})
+	if err != nil {
+		t.Fatalf("Error creating the validator: %v", err)
+	}
+
+	hash, err := auth.HashPassword(handlerTestPassword)
+	if err != nil {
+		t.Fatalf("Error hashing the password: %v", err)
+	}
+	user := testUser()
+	user.HashedPassword = hash
+
+	c := &apiConfig{
+		db:                      q,
+		sqlDB:                   db,
+		jwtKeys:                 keys,
+		jwtValidator:            validator,
+		jwtAudience:             defaultJWTAudience,
+		mailer:                  testMailer{},
+		loginAccountLockout:     lockout.NewTracker(loginAccountPolicy),
+		loginIPLockout:          lockout.NewTracker(loginIPPolicy),
+		verificationResendLimit: lockout.NewTracker(resendVerificationPolicy),
+	}
+	return c, f, user
+}
+
+func userRow(u database.User) [][]driver.Value {
+	var verifiedAt driver.Value
+	if u.EmailVerifiedAt.Valid {
+		verifiedAt = u.EmailVerifiedAt.Time
+	}
+	return [][]driver.Value{{
+		u.ID.String(), u.CreatedAt, u.UpdatedAt, u.Email, u.HashedPassword, u.IsChirpyRed,
+		u.Role, verifiedAt, u.Username, u.DisplayName, u.Bio, u.AvatarUrl,
+	}}
+}
+
+func refreshTokenRow(userID, familyID uuid.UUID) [][]driver.Value {
+	return [][]driver.Value{{
+		testTokenHash, time.Now(), time.Now(), userID.String(), time.Now().Add(time.Hour), nil, familyID.String(), nil,
+	}}
+}
+
+// serveHandler sends the request to the handler and checks the status and that the body
+// has none of the sensitive keys, the password hash of the user nor the token hashes.
+func serveHandler(t *testing.T, handler http.HandlerFunc, req *http.Request, wantStatus int, user database.User) map[string]any {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler(w, req)
+
+	if w.Code != wantStatus {
+		t.Fatalf("Expected %d, got %d: %s", wantStatus, w.Code, w.Body)
+	}
+
+	body := w.Body.String()
+	for _, secret := range []string{user.HashedPassword, testTokenHash, handlerTestPassword} {
+		if strings.Contains(body, secret) {
+			t.Errorf("Expected no secret in %s", body)
+		}
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &fields); err != nil {
+		t.Fatalf("Error decoding %s: %v", body, err)
+	}
+	for _, key := range sensitiveKeys {
+		if _, ok := fields[key]; ok {
+			t.Errorf("Expected no %q field in %s", key, body)
+		}
+	}
+	return fields
+}
+
+func TestHandlerCreateUser_NoSecrets(t *testing.T) {
+	c, f, user := newHandlerTestConfig(t)
+	f.answer("GetUserByUsername", nil)
+	f.answer("CreateUser", func([]driver.NamedValue) [][]driver.Value { return userRow(user) })
+	f.answer("DeleteEmailVerificationTokens", nil)
+	f.answer("CreateEmailVerificationToken", nil)
+
+	body := `{"email": "gopher@example.com", "password": "` + handlerTestPassword + `", "username": "gopher"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader(body))
+	fields := serveHandler(t, c.handlerCreateUser, req, http.StatusCreated, user)
+
+	if fields["email"] != user.Email {
+		t.Fatalf("Expected the account in the response, got %v", fields)
+	}
+}
+
+func TestHandlerLogin_NoSecrets(t *testing.T) {
+	c, f, user := newHandlerTestConfig(t)
+	sessionID := uuid.New()
+	f.answer("GetUserByEmail", func([]driver.NamedValue) [][]driver.Value { return userRow(user) })
+	f.answer("CreateSession", func([]driver.NamedValue) [][]driver.Value {
+		return [][]driver.Value{{sessionID.String(), user.ID.String(), time.Now(), time.Now(), "test", "192.0.2.1"}}
+	})
+	f.answer("CreateRefreshToken", func([]driver.NamedValue) [][]driver.Value { return refreshTokenRow(user.ID, sessionID) })
+
+	body := `{"email": "gopher@example.com", "password": "` + handlerTestPassword + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(body))
+	fields := serveHandler(t, c.handlerLogin, req, http.StatusOK, user)
+
+	if fields["token"] == "" || fields["refresh_token"] == "" || fields["email"] != user.Email {
+		t.Fatalf("Expected the account and the tokens in the response, got %v", fields)
+	}
+}
+
+func TestHandlerUpdateUser_NoSecrets(t *testing.T) {
+	c, f, user := newHandlerTestConfig(t)
+	f.answer("GetUserByID", func([]driver.NamedValue) [][]driver.Value { return userRow(user) })
+	f.answer("UpdateUser", func([]driver.NamedValue) [][]driver.Value { return userRow(user) })
+	f.answer("RevokeOtherUserSessions", nil)
+	f.answer("DeletePasswordResetTokens", nil)
+
+	token, err := c.jwtKeys.MakeJWT(user.ID, user.Role, uuid.New(), c.jwtAudience, time.Hour)
+	if err != nil {
+		t.Fatalf("Error making the token: %v", err)
+	}
+
+	body := `{"password": "a new password", "current_password": "` + handlerTestPassword + `"}`
+	req := httptest.NewRequest(http.MethodPut, "/api/users", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	fields := serveHandler(t, c.middlewareAuth(c.handlerUpdateUser), req, http.StatusOK, user)
+
+	if fields["username"] != user.Username {
+		t.Fatalf("Expected the account in the response, got %v", fields)
+	}
+}
+
+func TestHandlerRefreshToken_NoSecrets(t *testing.T) {
+	c, f, user := newHandlerTestConfig(t)
+	familyID := uuid.New()
+	f.answer("RotateRefreshToken", func([]driver.NamedValue) [][]driver.Value { return refreshTokenRow(user.ID, familyID) })
+	f.answer("GetUserByID", func([]driver.NamedValue) [][]driver.Value { return userRow(user) })
+	f.answer("CreateRefreshToken", func([]driver.NamedValue) [][]driver.Value { return refreshTokenRow(user.ID, familyID) })
+	f.answer("TouchSession", nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/refresh", nil)
+	req.Header.Set("Authorization", "Bearer some-refresh-token")
+	fields := serveHandler(t, c.handlerRefreshToken, req, http.StatusOK, user)
+
+	if fields["token"] == "" || fields["refresh_token"] == "" {
+		t.Fatalf("Expected the tokens in the response, got %v", fields)
+	}
+}
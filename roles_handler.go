@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/federicoReghini/Chirpy/internal/auth"
+	"github.com/federicoReghini/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type updateUserRoleRequest struct {
+	Role string `json:"role"`
+}
+
+type userRoleResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// handlerUpdateUserRole sets the role of the user in the path: {"role": "user" | "moderator" | "admin"}.
+// Admins cannot change their own role, so there is always an admin left to undo a change.
+// The new role is carried by the tokens issued from the next login or refresh.
+// It is registered as a handler for the "/admin/users/{userID}/role" endpoint with the PUT method.
+func (c *apiConfig) handlerUpdateUserRole(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if userID == userIDFromContext(req.Context()) {
+		marshalError(w, http.StatusBadRequest, "Cannot change your own role")
+		return
+	}
+
+	params := updateUserRoleRequest{}
+	decoder := json.NewDecoder(req.Body)
+	if err := decoder.Decode(&params); err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if !auth.ValidRole(params.Role) {
+		marshalError(w, http.StatusBadRequest, "Invalid role")
+		return
+	}
+
+	user, err := c.db.UpdateUserRole(req.Context(), database.UpdateUserRoleParams{
+		Role: params.Role,
+		ID:   userID,
+	})
+	if err != nil {
+		marshalError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	marshalOkJson(w, http.StatusOK, userRoleResponse{
+		ID:        user.ID,
+		Email:     user.Email,
+		Role:      user.Role,
+		UpdatedAt: user.UpdatedAt,
+	})
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/federicoReghini/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// searchCursor is the position in the ranked search results.
+// Ranking depends on the query, so results are paged by offset instead of by creation time.
+type searchCursor struct {
+	Offset int32 `json:"offset"`
+}
+
+// parseTimeParam parses a date filter given either as RFC 3339 or as a plain date (2006-01-02).
+// An empty value gives an invalid sql.NullTime so the filter is skipped.
+func parseTimeParam(value string) (sql.NullTime, error) {
+	if value == "" {
+		return sql.NullTime{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return sql.NullTime{Time: t.UTC(), Valid: true}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return sql.NullTime{Time: t, Valid: true}, nil
+	}
+
+	return sql.NullTime{}, errors.New("Invalid date, use RFC 3339 or YYYY-MM-DD")
+}
+
+// handlerSearchChirps runs a full-text search over the chirp bodies.
+// Results are ordered by relevance, then newest first.
+// Query parameters:
+//   - q: the search terms (required), supports "quoted phrases", OR and -exclusions
+//   - author_id: only return chirps of this user
+//   - since, until: only return chirps created in [since, until)
+//   - limit, cursor: pagination, as in handlerGetChips
+//
+// It is registered as a handler for the "/chirps/search" endpoint with the GET method.
+func (c *apiConfig) handlerSearchChirps(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	query := req.URL.Query()
+
+	searchQuery := strings.TrimSpace(query.Get("q"))
+	if searchQuery == "" {
+		marshalError(w, http.StatusBadRequest, "Missing search query")
+		return
+	}
+
+	authorID := uuid.NullUUID{}
+	if authorIDString := query.Get("author_id"); authorIDString != "" {
+		parsedID, err := uuid.Parse(authorIDString)
+		if err != nil {
+			marshalError(w, http.StatusBadRequest, "Invalid author ID")
+			return
+		}
+		authorID = uuid.NullUUID{UUID: parsedID, Valid: true}
+	}
+
+	since, err := parseTimeParam(query.Get("since"))
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	until, err := parseTimeParam(query.Get("until"))
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	limit, err := parsePageLimit(query)
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cursor := searchCursor{}
+	if cursorString := query.Get("cursor"); cursorString != "" {
+		if err := decodeCursor(cursorString, &cursor); err != nil || cursor.Offset < 0 {
+			marshalError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+	}
+
+	rows, err := c.db.SearchChirps(req.Context(), database.SearchChirpsParams{
+		Query:      searchQuery,
+		AuthorID:   authorID,
+		Since:      since,
+		Until:      until,
+		PageLimit:  limit + 1,
+		PageOffset: cursor.Offset,
+	})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	hasMore := len(rows) > int(limit)
+	if hasMore {
+		rows = rows[:limit]
+	}
+
+	dbChirps := make([]database.Chirp, 0, len(rows))
+	for _, row := range rows {
+		dbChirps = append(dbChirps, database.Chirp{
+			ID:        row.ID,
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+			Body:      row.Body,
+			UserID:    row.UserID,
+			InReplyTo: row.InReplyTo,
+			Kind:      row.Kind,
+			RechirpOf: row.RechirpOf,
+			QuoteOf:   row.QuoteOf,
+		})
+	}
+
+	chirps, err := c.newChirpResponses(req.Context(), dbChirps, optionalUserIDFromContext(req.Context()))
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	page := chirpsPage{
+		Chirps:  chirps,
+		HasMore: hasMore,
+	}
+	if hasMore {
+		page.NextCursor = encodeCursor(searchCursor{Offset: cursor.Offset + limit})
+	}
+
+	marshalOkJson(w, http.StatusOK, page)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/federicoReghini/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type sessionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	// Current is true for the session of the token used for the request
+	Current bool `json:"current"`
+}
+
+// handlerGetSessions lists the active sessions of the authenticated user, most recently used first.
+// A session is a login whose refresh token is neither revoked nor expired.
+// It is registered as a handler for the "/sessions" endpoint with the GET method.
+func (c *apiConfig) handlerGetSessions(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	userID := userIDFromContext(req.Context())
+	currentSessionID := claimsFromContext(req.Context()).SessionUUID()
+
+	dbSessions, err := c.db.GetActiveSessions(req.Context(), userID)
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	sessions := []sessionResponse{}
+	for _, dbSession := range dbSessions {
+		sessions = append(sessions, sessionResponse{
+			ID:         dbSession.ID,
+			CreatedAt:  dbSession.CreatedAt,
+			LastUsedAt: dbSession.LastUsedAt,
+			UserAgent:  dbSession.UserAgent,
+			IP:         dbSession.Ip,
+			Current:    currentSessionID.Valid && currentSessionID.UUID == dbSession.ID,
+		})
+	}
+
+	marshalOkJson(w, http.StatusOK, sessions)
+}
+
+// handlerRevokeSession logs out the session in the path by revoking its refresh token.
+// The JWTs already issued for the session stay valid until they expire.
+// It is registered as a handler for the "/sessions/{sessionID}" endpoint with the DELETE method.
+func (c *apiConfig) handlerRevokeSession(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	sessionID, err := uuid.Parse(req.PathValue("sessionID"))
+	if err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	revoked, err := c.db.RevokeSession(req.Context(), database.RevokeSessionParams{
+		SessionID: sessionID,
+		UserID:    userIDFromContext(req.Context()),
+	})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if revoked == 0 {
+		marshalError(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerRevokeAllSessions logs out everywhere by revoking every refresh token of the authenticated user,
+// including the one of the current session.
+// It is registered as a handler for the "/sessions" endpoint with the DELETE method.
+func (c *apiConfig) handlerRevokeAllSessions(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	userID := userIDFromContext(req.Context())
+
+	err := c.db.RevokeUserSessions(req.Context(), uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
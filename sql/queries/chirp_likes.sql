@@ -0,0 +1,23 @@
+-- name: LikeChirp :exec
+INSERT INTO chirp_likes (user_id, chirp_id, created_at)
+VALUES (
+  $1,
+  $2,
+  NOW()
+)
+ON CONFLICT (user_id, chirp_id) DO NOTHING;
+
+-- name: UnlikeChirp :exec
+DELETE FROM chirp_likes
+  WHERE chirp_likes.user_id = $1 AND chirp_likes.chirp_id = $2;
+
+-- name: CountChirpLikes :many
+SELECT chirp_likes.chirp_id, COUNT(*) AS like_count
+FROM chirp_likes
+WHERE chirp_likes.chirp_id = ANY(@chirp_ids::uuid[])
+GROUP BY chirp_likes.chirp_id;
+
+-- name: GetLikedChirpIDs :many
+SELECT chirp_likes.chirp_id
+FROM chirp_likes
+WHERE chirp_likes.user_id = @user_id AND chirp_likes.chirp_id = ANY(@chirp_ids::uuid[]);
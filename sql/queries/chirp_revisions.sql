@@ -0,0 +1,17 @@
+-- name: UpdateChirp :one
+WITH revision AS (
+  INSERT INTO chirp_revisions (id, chirp_id, body, created_at, replaced_at)
+  SELECT gen_random_uuid(), chirps.id, chirps.body, chirps.updated_at, NOW()
+  FROM chirps
+  WHERE chirps.id = @id AND chirps.user_id = @user_id
+  FOR UPDATE
+)
+UPDATE chirps
+SET body = @body, updated_at = NOW()
+WHERE chirps.id = @id AND chirps.user_id = @user_id
+RETURNING *;
+
+-- name: GetChirpRevisions :many
+SELECT * FROM chirp_revisions
+WHERE chirp_revisions.chirp_id = $1
+ORDER BY chirp_revisions.replaced_at ASC;
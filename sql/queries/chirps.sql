@@ -1,30 +1,126 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to, kind, quote_of)
 VALUES (
   gen_random_uuid(),
   NOW(),
   NOW(),
   $1,
+  $2,
+  $3,
+  $4,
+  $5
+)
+RETURNING *;
+
+-- name: CreateRechirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, kind, rechirp_of)
+VALUES (
+  gen_random_uuid(),
+  NOW(),
+  NOW(),
+  '',
+  $1,
+  'rechirp',
   $2
 )
+ON CONFLICT (user_id, rechirp_of) WHERE rechirp_of IS NOT NULL DO NOTHING
 RETURNING *;
 
--- name: GetChirps :many
+-- name: DeleteRechirp :execrows
+DELETE FROM chirps
+  WHERE chirps.user_id = $1 AND chirps.rechirp_of = $2 AND chirps.kind = 'rechirp';
+
+-- name: ListChirpsAsc :many
 SELECT * FROM chirps
-ORDER BY 
-CASE  WHEN $1 = 'asc' THEN  created_at END ASC,
-CASE  WHEN $1 = 'desc' THEN  created_at END DESC;
+WHERE (sqlc.narg('author_id')::uuid IS NULL OR chirps.user_id = sqlc.narg('author_id')::uuid)
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+  )
+  AND chirps.hidden_at IS NULL
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT @page_limit;
+
+-- name: ListChirpsDesc :many
+SELECT * FROM chirps
+WHERE (sqlc.narg('author_id')::uuid IS NULL OR chirps.user_id = sqlc.narg('author_id')::uuid)
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+  )
+  AND chirps.hidden_at IS NULL
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT @page_limit;
 
 -- name: GetChirpsByUser :many
 SELECT *
   FROM chirps 
-  WHERE chirps.user_id = $1;
+  WHERE chirps.user_id = $1 AND chirps.hidden_at IS NULL;
 
 -- name: GetChirp :one
 SELECT * FROM chirps
-WHERE chirps.id = $1;
+WHERE chirps.id = $1 AND chirps.hidden_at IS NULL;
+
+-- name: GetAuthorChirp :one
+-- Hidden chirps included, their author can still edit and delete them
+SELECT * FROM chirps
+WHERE chirps.id = @id AND chirps.user_id = @user_id;
+
+-- name: GetChirpReplies :many
+SELECT * FROM chirps
+WHERE chirps.in_reply_to = @chirp_id
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+  )
+  AND chirps.hidden_at IS NULL
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT @page_limit;
+
+-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors (id, depth) AS (
+  SELECT reply.in_reply_to, 1
+  FROM chirps AS reply
+  WHERE reply.id = $1 AND reply.in_reply_to IS NOT NULL
+  UNION ALL
+  SELECT chirps.in_reply_to, ancestors.depth + 1
+  FROM chirps
+  JOIN ancestors ON chirps.id = ancestors.id
+  WHERE chirps.in_reply_to IS NOT NULL AND ancestors.depth < 100
+)
+SELECT chirps.* FROM chirps
+JOIN ancestors ON chirps.id = ancestors.id
+WHERE chirps.hidden_at IS NULL
+ORDER BY ancestors.depth DESC;
+
+-- name: GetChirpDescendants :many
+WITH RECURSIVE descendants (id, depth) AS (
+  SELECT chirps.id, 1
+  FROM chirps
+  WHERE chirps.in_reply_to = $1
+  UNION ALL
+  SELECT chirps.id, descendants.depth + 1
+  FROM chirps
+  JOIN descendants ON chirps.in_reply_to = descendants.id
+  WHERE descendants.depth < 100
+)
+SELECT chirps.* FROM chirps
+JOIN descendants ON chirps.id = descendants.id
+WHERE chirps.hidden_at IS NULL
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT 1000;
 
 -- name: DeleteChirp :exec
 DELETE FROM chirps 
   WHERE chirps.user_id = $1 AND chirps.id = $2;
 
+-- name: SearchChirps :many
+SELECT chirps.*, ts_rank(chirps.body_tsv, websearch_to_tsquery('english', @query::text)) AS rank
+FROM chirps
+WHERE chirps.body_tsv @@ websearch_to_tsquery('english', @query::text)
+  AND (sqlc.narg('author_id')::uuid IS NULL OR chirps.user_id = sqlc.narg('author_id')::uuid)
+  AND (sqlc.narg('since')::timestamp IS NULL OR chirps.created_at >= sqlc.narg('since')::timestamp)
+  AND (sqlc.narg('until')::timestamp IS NULL OR chirps.created_at < sqlc.narg('until')::timestamp)
+  AND chirps.hidden_at IS NULL
+ORDER BY rank DESC, chirps.created_at DESC, chirps.id DESC
+LIMIT @page_limit OFFSET @page_offset;
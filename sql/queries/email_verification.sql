@@ -0,0 +1,20 @@
+-- name: CreateEmailVerificationToken :exec
+INSERT INTO email_verification_tokens (token_hash, user_id, email, created_at, expires_at)
+VALUES (
+  $1,
+  $2,
+  $3,
+  NOW(),
+  $4
+);
+
+-- name: UseEmailVerificationToken :one
+-- A token works until it expires, so a link opened first by a mail scanner still works for the user
+UPDATE email_verification_tokens
+SET used_at = COALESCE(used_at, NOW())
+WHERE token_hash = $1 AND expires_at > NOW()
+RETURNING *;
+
+-- name: DeleteEmailVerificationTokens :exec
+DELETE FROM email_verification_tokens
+WHERE user_id = $1;
@@ -0,0 +1,34 @@
+-- name: FollowUser :exec
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES (
+  $1,
+  $2,
+  NOW()
+)
+ON CONFLICT (follower_id, followee_id) DO NOTHING;
+
+-- name: UnfollowUser :exec
+DELETE FROM follows
+  WHERE follows.follower_id = $1 AND follows.followee_id = $2;
+
+-- name: GetFollowers :many
+SELECT * FROM follows
+WHERE follows.followee_id = $1
+ORDER BY follows.created_at DESC;
+
+-- name: GetFollowing :many
+SELECT * FROM follows
+WHERE follows.follower_id = $1
+ORDER BY follows.created_at DESC;
+
+-- name: GetTimeline :many
+SELECT chirps.* FROM chirps
+JOIN follows ON follows.followee_id = chirps.user_id
+WHERE follows.follower_id = @follower_id
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+  )
+  AND chirps.hidden_at IS NULL
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT @page_limit;
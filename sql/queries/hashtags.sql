@@ -0,0 +1,44 @@
+-- name: UpsertHashtag :one
+INSERT INTO hashtags (id, tag, created_at)
+VALUES (
+  gen_random_uuid(),
+  $1,
+  NOW()
+)
+ON CONFLICT (tag) DO UPDATE SET tag = EXCLUDED.tag
+RETURNING *;
+
+-- name: AddChirpHashtag :exec
+INSERT INTO chirp_hashtags (chirp_id, hashtag_id)
+VALUES ($1, $2)
+ON CONFLICT (chirp_id, hashtag_id) DO NOTHING;
+
+-- name: DeleteChirpHashtags :exec
+DELETE FROM chirp_hashtags
+  WHERE chirp_hashtags.chirp_id = $1;
+
+-- name: GetChirpsByHashtag :many
+SELECT chirps.* FROM chirps
+JOIN chirp_hashtags ON chirp_hashtags.chirp_id = chirps.id
+JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+WHERE hashtags.tag = @tag
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+  )
+  AND chirps.hidden_at IS NULL
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT @page_limit;
+
+-- name: GetTrendingHashtags :many
+SELECT hashtags.tag,
+  COUNT(*) AS chirp_count,
+  SUM(EXP(-LN(2) * EXTRACT(EPOCH FROM (NOW()::timestamp - chirps.created_at)) / @half_life_seconds::float8))::float8 AS score
+FROM hashtags
+JOIN chirp_hashtags ON chirp_hashtags.hashtag_id = hashtags.id
+JOIN chirps ON chirps.id = chirp_hashtags.chirp_id
+WHERE chirps.created_at >= NOW()::timestamp - make_interval(secs => @window_seconds::float8)
+  AND chirps.hidden_at IS NULL
+GROUP BY hashtags.tag
+ORDER BY score DESC, hashtags.tag ASC
+LIMIT @max_results;
@@ -0,0 +1,51 @@
+-- name: CreateMedia :one
+INSERT INTO media (id, user_id, content_type, size_bytes, width, height, storage_key, thumbnail_key, created_at)
+VALUES (
+  gen_random_uuid(),
+  $1,
+  $2,
+  $3,
+  $4,
+  $5,
+  $6,
+  $7,
+  NOW()
+)
+RETURNING *;
+
+-- name: GetMedia :one
+SELECT * FROM media WHERE id = $1;
+
+-- name: AttachMediaToChirp :execrows
+-- Only the unattached media of the chirp author can be attached, and not their avatar
+UPDATE media
+SET chirp_id = @chirp_id, position = @position
+WHERE id = @id AND user_id = @user_id AND chirp_id IS NULL
+  AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = media.user_id
+      AND right(users.avatar_url, length(media.storage_key) + 7) = '/media/' || media.storage_key
+  );
+
+-- name: GetChirpsMedia :many
+SELECT * FROM media
+WHERE chirp_id = ANY(@chirp_ids::uuid[])
+ORDER BY chirp_id, position;
+
+-- name: DeleteChirpMedia :many
+-- The files of the deleted media are removed from the storage by the server
+DELETE FROM media
+WHERE media.chirp_id = $1
+RETURNING media.storage_key, media.thumbnail_key;
+
+-- name: DeleteUnattachedMedia :many
+-- The uploads older than created_before not attached to a chirp nor used as the avatar of their owner,
+-- including the media of the chirps deleted without DeleteChirpMedia
+DELETE FROM media
+WHERE media.chirp_id IS NULL AND media.created_at < @created_before
+  AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = media.user_id
+      AND right(users.avatar_url, length(media.storage_key) + 7) = '/media/' || media.storage_key
+  )
+RETURNING media.storage_key, media.thumbnail_key;
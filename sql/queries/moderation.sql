@@ -0,0 +1,39 @@
+-- name: GetModerationRules :many
+SELECT * FROM moderation_rules
+ORDER BY moderation_rules.word ASC;
+
+-- name: UpsertModerationRule :one
+INSERT INTO moderation_rules (word, action, created_at, updated_at)
+VALUES (
+  $1,
+  $2,
+  NOW(),
+  NOW()
+)
+ON CONFLICT (word) DO UPDATE SET action = EXCLUDED.action, updated_at = NOW()
+RETURNING *;
+
+-- name: DeleteModerationRule :execrows
+DELETE FROM moderation_rules
+  WHERE moderation_rules.word = $1;
+
+-- name: CreateChirpFlag :one
+INSERT INTO chirp_flags (id, chirp_id, matched_words, created_at, reviewed_at)
+VALUES (
+  gen_random_uuid(),
+  $1,
+  $2,
+  NOW(),
+  null
+)
+RETURNING *;
+
+-- name: GetOpenChirpFlags :many
+SELECT * FROM chirp_flags
+WHERE chirp_flags.reviewed_at IS NULL
+ORDER BY chirp_flags.created_at ASC;
+
+-- name: ReviewChirpFlag :execrows
+UPDATE chirp_flags
+SET reviewed_at = NOW()
+WHERE chirp_flags.id = $1 AND chirp_flags.reviewed_at IS NULL;
@@ -1,21 +1,35 @@
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (token, created_at, updated_at,  user_id, expires_at, revoked_at)
+INSERT INTO refresh_tokens (token_hash, created_at, updated_at,  user_id, expires_at, revoked_at, family_id)
 VALUES (
   $1,
   NOW(),
   NOW(),
   $2,
   $3,
-  null
+  null,
+  $4
 )
 RETURNING *; 
 
 -- name: GetRefreshTokenByToken :one
 SELECT * FROM refresh_tokens
-WHERE refresh_tokens.token = $1;
+WHERE refresh_tokens.token_hash = $1;
 
 
 -- name: RevokeRefreshToken :exec
 UPDATE refresh_tokens
 SET revoked_at = NOW(), updated_at = NOW()
-WHERE refresh_tokens.token = $1;
+WHERE refresh_tokens.token_hash = $1;
+
+-- name: RotateRefreshToken :one
+UPDATE refresh_tokens
+SET rotated_at = NOW(), revoked_at = NOW(), updated_at = NOW()
+WHERE refresh_tokens.token_hash = $1
+  AND refresh_tokens.revoked_at IS NULL
+  AND refresh_tokens.expires_at > NOW()
+RETURNING *;
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE refresh_tokens.family_id = $1 AND refresh_tokens.revoked_at IS NULL;
@@ -0,0 +1,81 @@
+-- name: CreateReport :one
+INSERT INTO reports (id, chirp_id, reporter_id, reason, details, created_at)
+VALUES (
+  gen_random_uuid(),
+  $1,
+  $2,
+  $3,
+  $4,
+  NOW()
+)
+ON CONFLICT (chirp_id, reporter_id) WHERE resolved_at IS NULL DO NOTHING
+RETURNING *;
+
+-- name: GetReport :one
+-- GetReport locks the report, so a concurrent resolution waits and sees it resolved.
+SELECT * FROM reports
+WHERE reports.id = $1
+FOR UPDATE;
+
+-- name: GetOpenReports :many
+SELECT reports.*, chirps.body AS chirp_body, chirps.user_id AS chirp_user_id, chirps.hidden_at AS chirp_hidden_at
+FROM reports
+JOIN chirps ON chirps.id = reports.chirp_id
+WHERE reports.resolved_at IS NULL
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (reports.created_at, reports.id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+  )
+ORDER BY reports.created_at ASC, reports.id ASC
+LIMIT @page_limit;
+
+-- name: ResolveChirpReports :execrows
+UPDATE reports
+SET resolved_at = NOW(), resolved_by = @moderator_id, resolution = @resolution
+WHERE reports.chirp_id = @chirp_id AND reports.resolved_at IS NULL;
+
+-- name: ResolveDeletedChirpReports :execrows
+-- The open reports of a deleted chirp and of its rechirps, deleted with it
+UPDATE reports
+SET resolved_at = NOW(), resolution = 'deleted'
+WHERE reports.resolved_at IS NULL
+  AND (
+    reports.chirp_id = @chirp_id
+    OR reports.chirp_id IN (SELECT chirps.id FROM chirps WHERE chirps.rechirp_of = @chirp_id)
+  );
+
+-- name: CreateModerationAction :one
+INSERT INTO moderation_actions (id, moderator_id, action, chirp_id, report_id, note, created_at)
+VALUES (
+  gen_random_uuid(),
+  $1,
+  $2,
+  $3,
+  $4,
+  $5,
+  NOW()
+)
+RETURNING *;
+
+-- name: GetModerationActions :many
+SELECT * FROM moderation_actions
+WHERE (sqlc.narg('chirp_id')::uuid IS NULL OR moderation_actions.chirp_id = sqlc.narg('chirp_id')::uuid)
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (moderation_actions.created_at, moderation_actions.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+  )
+ORDER BY moderation_actions.created_at DESC, moderation_actions.id DESC
+LIMIT @page_limit;
+
+-- name: GetChirpForModeration :one
+SELECT * FROM chirps
+WHERE chirps.id = $1;
+
+-- name: SetChirpHidden :execrows
+UPDATE chirps
+SET hidden_at = CASE WHEN @hidden::boolean THEN NOW() ELSE NULL END
+WHERE chirps.id = @id;
+
+-- name: RemoveChirp :execrows
+DELETE FROM chirps
+  WHERE chirps.id = $1;
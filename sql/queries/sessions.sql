@@ -0,0 +1,49 @@
+-- name: CreateSession :one
+INSERT INTO sessions (id, user_id, created_at, last_used_at, user_agent, ip)
+VALUES (
+  gen_random_uuid(),
+  $1,
+  NOW(),
+  NOW(),
+  $2,
+  $3
+)
+RETURNING *;
+
+-- name: TouchSession :exec
+UPDATE sessions
+SET last_used_at = NOW(), user_agent = $2, ip = $3
+WHERE sessions.id = $1;
+
+-- name: GetActiveSessions :many
+SELECT * FROM sessions
+WHERE sessions.user_id = $1
+  AND EXISTS (
+    SELECT 1 FROM refresh_tokens
+    WHERE refresh_tokens.family_id = sessions.id
+      AND refresh_tokens.revoked_at IS NULL
+      AND refresh_tokens.expires_at > NOW()
+  )
+ORDER BY sessions.last_used_at DESC;
+
+-- name: RevokeSession :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+FROM sessions
+WHERE refresh_tokens.family_id = sessions.id
+  AND sessions.id = @session_id
+  AND sessions.user_id = @user_id
+  AND refresh_tokens.revoked_at IS NULL;
+
+-- name: RevokeUserSessions :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE refresh_tokens.user_id = $1 AND refresh_tokens.revoked_at IS NULL;
+
+-- name: RevokeOtherUserSessions :exec
+-- Revokes every session of the user except the given one, all of them when it is null
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE refresh_tokens.user_id = sqlc.arg('user_id')
+  AND refresh_tokens.family_id IS DISTINCT FROM sqlc.narg('keep_session_id')
+  AND refresh_tokens.revoked_at IS NULL;
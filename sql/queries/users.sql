@@ -1,14 +1,15 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password, is_chirpy_red)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, is_chirpy_red, username)
 VALUES (
   gen_random_uuid(),
   NOW(),
   NOW(),
   $1,
   $2,
-  false
+  false,
+  $3
 )
-RETURNING id, created_at, updated_at, email, is_chirpy_red;
+RETURNING *;
 
 -- name: GetUserByEmail :one
 SELECT * FROM users
@@ -21,16 +22,52 @@ DELETE FROM users;
 SELECT * FROM users 
 WHERE users.id = (
   SELECT user_id FROM refresh_tokens
-  WHERE refresh_tokens.token = $1
+  WHERE refresh_tokens.token_hash = $1
 );
 
 -- name: UpdateUser :one
+-- Only the fields that are not null change. A new email has to be verified again
 UPDATE users
-  SET email = $1, hashed_password = $2
-  WHERE users.id = $3
+  SET email = COALESCE(sqlc.narg('email'), users.email),
+    hashed_password = COALESCE(sqlc.narg('hashed_password'), users.hashed_password),
+    username = COALESCE(sqlc.narg('username'), users.username),
+    display_name = COALESCE(sqlc.narg('display_name'), users.display_name),
+    bio = COALESCE(sqlc.narg('bio'), users.bio),
+    avatar_url = COALESCE(sqlc.narg('avatar_url'), users.avatar_url),
+    email_verified_at = CASE
+      WHEN sqlc.narg('email')::text IS NULL OR users.email = sqlc.narg('email') THEN users.email_verified_at
+    END,
+    updated_at = NOW()
+  WHERE users.id = sqlc.arg('id')
   RETURNING *;
 
 -- name: UpdateUserToPremium :exec
 UPDATE users
   SET is_chirpy_red = true 
   WHERE users.id = $1;
+
+-- name: GetUserByUsername :one
+SELECT * FROM users
+WHERE users.username = $1;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE users.id = $1;
+
+-- name: UpdateUserRole :one
+UPDATE users
+  SET role = $1, updated_at = NOW()
+  WHERE users.id = $2
+  RETURNING *;
+
+-- name: MarkUserEmailVerified :execrows
+-- Verifying an email already verified changes nothing but still matches the user
+UPDATE users
+  SET email_verified_at = COALESCE(users.email_verified_at, NOW()),
+    updated_at = CASE WHEN users.email_verified_at IS NULL THEN NOW() ELSE users.updated_at END
+  WHERE users.id = $1 AND users.email = $2;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+  SET hashed_password = $1, updated_at = NOW()
+  WHERE users.id = $2;
@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE chirps
+  ADD COLUMN kind TEXT NOT NULL DEFAULT 'chirp' CHECK (kind IN ('chirp', 'rechirp', 'quote')),
+  ADD COLUMN rechirp_of UUID REFERENCES chirps(id) ON DELETE CASCADE,
+  ADD COLUMN quote_of UUID REFERENCES chirps(id) ON DELETE SET NULL,
+  ADD CONSTRAINT chirps_rechirp_of_check CHECK (kind <> 'rechirp' OR rechirp_of IS NOT NULL);
+
+-- A user can rechirp a chirp only once
+CREATE UNIQUE INDEX chirps_user_id_rechirp_of_idx ON chirps (user_id, rechirp_of) WHERE rechirp_of IS NOT NULL;
+CREATE INDEX chirps_quote_of_idx ON chirps (quote_of);
+
+-- +goose Down
+DROP INDEX chirps_quote_of_idx;
+DROP INDEX chirps_user_id_rechirp_of_idx;
+
+ALTER TABLE chirps
+  DROP CONSTRAINT chirps_rechirp_of_check,
+  DROP COLUMN quote_of,
+  DROP COLUMN rechirp_of,
+  DROP COLUMN kind;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- body is stored already censored, so the index only contains the censored text
+ALTER TABLE chirps
+  ADD COLUMN body_tsv TSVECTOR GENERATED ALWAYS AS (to_tsvector('english', body)) STORED;
+
+CREATE INDEX chirps_body_tsv_idx ON chirps USING GIN (body_tsv);
+
+-- +goose Down
+DROP INDEX chirps_body_tsv_idx;
+
+ALTER TABLE chirps
+  DROP COLUMN body_tsv;
@@ -0,0 +1,28 @@
+-- +goose Up
+CREATE TABLE moderation_rules (
+  word TEXT PRIMARY KEY,
+  action TEXT NOT NULL CHECK (action IN ('censor', 'reject', 'flag')),
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+
+-- The words that used to be hard-coded in handlerValidateChirp
+INSERT INTO moderation_rules (word, action, created_at, updated_at)
+VALUES
+  ('kerfuffle', 'censor', NOW(), NOW()),
+  ('sharbert', 'censor', NOW(), NOW()),
+  ('fornax', 'censor', NOW(), NOW());
+
+CREATE TABLE chirp_flags (
+  id UUID PRIMARY KEY,
+  chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  matched_words TEXT[] NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  reviewed_at TIMESTAMP
+);
+
+CREATE INDEX chirp_flags_open_idx ON chirp_flags (created_at) WHERE reviewed_at IS NULL;
+
+-- +goose Down
+DROP TABLE chirp_flags;
+DROP TABLE moderation_rules;
@@ -0,0 +1,39 @@
+-- +goose Up
+-- Hidden chirps stay in the database for the moderators but are left out of every public read
+ALTER TABLE chirps ADD COLUMN hidden_at TIMESTAMP;
+
+CREATE TABLE reports (
+  id UUID PRIMARY KEY,
+  -- Set to null when the reported chirp is removed, the report stays for the audit trail
+  chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+  reporter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  reason TEXT NOT NULL CHECK (reason IN ('spam', 'harassment', 'hate', 'violence', 'misinformation', 'other')),
+  details TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL,
+  resolved_at TIMESTAMP,
+  resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  resolution TEXT CHECK (resolution IN ('hidden', 'removed', 'dismissed'))
+);
+
+-- A user can have only one open report per chirp
+CREATE UNIQUE INDEX reports_open_reporter_idx ON reports (chirp_id, reporter_id) WHERE resolved_at IS NULL;
+CREATE INDEX reports_open_idx ON reports (created_at) WHERE resolved_at IS NULL;
+
+-- The audit trail of what the moderators did
+CREATE TABLE moderation_actions (
+  id UUID PRIMARY KEY,
+  moderator_id UUID REFERENCES users(id) ON DELETE SET NULL,
+  action TEXT NOT NULL CHECK (action IN ('hide', 'unhide', 'remove', 'dismiss')),
+  -- Not a foreign key, the chirp of a remove action no longer exists
+  chirp_id UUID NOT NULL,
+  report_id UUID REFERENCES reports(id) ON DELETE SET NULL,
+  note TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX moderation_actions_chirp_idx ON moderation_actions (chirp_id, created_at);
+
+-- +goose Down
+DROP TABLE moderation_actions;
+DROP TABLE reports;
+ALTER TABLE chirps DROP COLUMN hidden_at;
@@ -0,0 +1,27 @@
+-- +goose Up
+-- A session is a login: the family of refresh tokens issued from it shares the session ID
+CREATE TABLE sessions (
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  last_used_at TIMESTAMP NOT NULL,
+  user_agent TEXT NOT NULL DEFAULT '',
+  ip TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX sessions_user_idx ON sessions (user_id, last_used_at);
+
+-- Tokens without a user cannot be used, the existing families become sessions
+DELETE FROM refresh_tokens WHERE user_id IS NULL;
+
+INSERT INTO sessions (id, user_id, created_at, last_used_at)
+SELECT family_id, (array_agg(user_id))[1], MIN(created_at), MAX(updated_at)
+FROM refresh_tokens
+GROUP BY family_id;
+
+ALTER TABLE refresh_tokens
+  ADD CONSTRAINT refresh_tokens_family_id_fkey FOREIGN KEY (family_id) REFERENCES sessions(id) ON DELETE CASCADE;
+
+-- +goose Down
+ALTER TABLE refresh_tokens DROP CONSTRAINT refresh_tokens_family_id_fkey;
+DROP TABLE sessions;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- Only the SHA-256 hash of a refresh token is stored, the existing tokens are hashed in place
+-- so the sessions stay logged in
+ALTER TABLE refresh_tokens RENAME COLUMN token TO token_hash;
+UPDATE refresh_tokens SET token_hash = encode(sha256(token_hash::bytea), 'hex');
+
+-- +goose Down
+-- The hashes cannot be reversed, every session has to log in again
+DELETE FROM refresh_tokens;
+ALTER TABLE refresh_tokens RENAME COLUMN token_hash TO token;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- The username is the public handle of the user, lowercase.
+-- The existing users get a handle derived from their ID, they can change it
+ALTER TABLE users
+  ADD COLUMN username TEXT,
+  ADD COLUMN display_name TEXT NOT NULL DEFAULT '',
+  ADD COLUMN bio TEXT NOT NULL DEFAULT '',
+  ADD COLUMN avatar_url TEXT NOT NULL DEFAULT '';
+
+UPDATE users SET username = 'user_' || substr(replace(id::text, '-', ''), 1, 12);
+
+ALTER TABLE users
+  ALTER COLUMN username SET NOT NULL,
+  ADD CONSTRAINT users_username_key UNIQUE (username),
+  ADD CONSTRAINT users_username_check CHECK (username ~ '^[a-z0-9_]{3,30}$');
+
+-- +goose Down
+ALTER TABLE users
+  DROP COLUMN avatar_url,
+  DROP COLUMN bio,
+  DROP COLUMN display_name,
+  DROP COLUMN username;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- An uploaded image, attached to at most one chirp.
+-- The files are in the storage, under storage_key and thumbnail_key
+CREATE TABLE media (
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  content_type TEXT NOT NULL,
+  size_bytes BIGINT NOT NULL,
+  width INTEGER NOT NULL,
+  height INTEGER NOT NULL,
+  storage_key TEXT NOT NULL UNIQUE,
+  thumbnail_key TEXT NOT NULL UNIQUE,
+  created_at TIMESTAMP NOT NULL,
+  chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+  position INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX media_chirp_idx ON media (chirp_id, position);
+
+-- +goose Down
+DROP TABLE media;
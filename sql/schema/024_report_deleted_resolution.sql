@@ -0,0 +1,17 @@
+-- +goose Up
+-- The reports of a chirp deleted by its author are resolved as 'deleted'
+ALTER TABLE reports
+  DROP CONSTRAINT reports_resolution_check,
+  ADD CONSTRAINT reports_resolution_check CHECK (resolution IN ('hidden', 'removed', 'dismissed', 'deleted'));
+
+-- The reports left open by the chirps deleted before
+UPDATE reports
+SET resolved_at = NOW(), resolution = 'deleted'
+WHERE reports.chirp_id IS NULL AND reports.resolved_at IS NULL;
+
+-- +goose Down
+UPDATE reports SET resolution = 'dismissed' WHERE resolution = 'deleted';
+
+ALTER TABLE reports
+  DROP CONSTRAINT reports_resolution_check,
+  ADD CONSTRAINT reports_resolution_check CHECK (resolution IN ('hidden', 'removed', 'dismissed'));
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/federicoReghini/Chirpy/internal/database"
+)
+
+const (
+	// Only chirps of the last trendingWindow are scored
+	trendingWindow = 24 * time.Hour
+	// A use of a hashtag counts half as much every trendingHalfLife
+	trendingHalfLife = 6 * time.Hour
+	// How often the background worker recomputes the scores
+	trendingInterval = 5 * time.Minute
+	// How many hashtags are kept, the endpoint can return at most this many
+	trendingMaxResults   = 50
+	defaultTrendingLimit = 10
+)
+
+type trendingHashtag struct {
+	Tag        string  `json:"tag"`
+	ChirpCount int64   `json:"chirp_count"`
+	Score      float64 `json:"score"`
+}
+
+type trendingResponse struct {
+	Hashtags   []trendingHashtag `json:"hashtags"`
+	ComputedAt *time.Time        `json:"computed_at"`
+}
+
+// trendingTracker holds the last computed trending hashtags.
+// It is written by the background worker and read by the handler.
+type trendingTracker struct {
+	mu         sync.RWMutex
+	hashtags   []trendingHashtag
+	computedAt time.Time
+}
+
+func (t *trendingTracker) set(hashtags []trendingHashtag, computedAt time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.hashtags = hashtags
+	t.computedAt = computedAt
+}
+
+func (t *trendingTracker) get() ([]trendingHashtag, time.Time) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.hashtags, t.computedAt
+}
+
+// rankTrendingHashtags returns the maxResults best hashtags of the rows scored by
+// GetTrendingHashtags, by decreasing score and then by tag.
+func rankTrendingHashtags(rows []database.GetTrendingHashtagsRow, maxResults int) []trendingHashtag {
+	hashtags := make([]trendingHashtag, 0, len(rows))
+	for _, row := range rows {
+		hashtags = append(hashtags, trendingHashtag{
+			Tag:        row.Tag,
+			ChirpCount: row.ChirpCount,
+			Score:      row.Score,
+		})
+	}
+
+	slices.SortStableFunc(hashtags, func(a, b trendingHashtag) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Tag, b.Tag)
+	})
+
+	if len(hashtags) > maxResults {
+		hashtags = hashtags[:maxResults]
+	}
+	return hashtags
+}
+
+// refreshTrending recomputes the trending hashtags.
+// Every use of a hashtag in the window adds exp(-ln2 * age / halfLife) to its score,
+// so recent uses weigh more and a use loses half of its weight every trendingHalfLife.
+// The scores are summed per hashtag by the database, only the best ones are read.
+func (c *apiConfig) refreshTrending(ctx context.Context) error {
+	rows, err := c.db.GetTrendingHashtags(ctx, database.GetTrendingHashtagsParams{
+		HalfLifeSeconds: trendingHalfLife.Seconds(),
+		WindowSeconds:   trendingWindow.Seconds(),
+		MaxResults:      trendingMaxResults,
+	})
+	if err != nil {
+		return err
+	}
+
+	c.trending.set(rankTrendingHashtags(rows, trendingMaxResults), time.Now().UTC())
+	return nil
+}
+
+// runTrendingWorker recomputes the trending hashtags right away and then every interval,
+// until ctx is done. Errors are logged and the previous scores are kept.
+func (c *apiConfig) runTrendingWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.refreshTrending(ctx); err != nil {
+			log.Printf("Error computing trending hashtags: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// handlerGetTrending returns the top hashtags of the last 24 hours by time-decayed score.
+// The scores are computed periodically by runTrendingWorker, not on every request,
+// computed_at tells when they were last refreshed (null until the first run completes).
+// The optional limit query parameter defaults to 10 and can be at most 50.
+// It is registered as a handler for the "/trending" endpoint with the GET method.
+func (c *apiConfig) handlerGetTrending(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	limit := defaultTrendingLimit
+	if limitString := req.URL.Query().Get("limit"); limitString != "" {
+		parsedLimit, err := strconv.Atoi(limitString)
+		if err != nil || parsedLimit < 1 || parsedLimit > trendingMaxResults {
+			marshalError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = parsedLimit
+	}
+
+	hashtags, computedAt := c.trending.get()
+	if len(hashtags) > limit {
+		hashtags = hashtags[:limit]
+	}
+
+	resp := trendingResponse{
+		Hashtags: []trendingHashtag{},
+	}
+	resp.Hashtags = append(resp.Hashtags, hashtags...)
+	if !computedAt.IsZero() {
+		resp.ComputedAt = &computedAt
+	}
+
+	marshalOkJson(w, http.StatusOK, resp)
+}
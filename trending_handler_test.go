@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/federicoReghini/Chirpy/internal/database"
+)
+
+func TestRankTrendingHashtags(t *testing.T) {
+	rows := []database.GetTrendingHashtagsRow{
+		{Tag: "rust", ChirpCount: 3, Score: 0.75},
+		{Tag: "zig", ChirpCount: 1, Score: 1},
+		{Tag: "go", ChirpCount: 2, Score: 1.5},
+		// zig and c tie at 1, the tag breaks the tie
+		{Tag: "c", ChirpCount: 1, Score: 1},
+	}
+
+	got := rankTrendingHashtags(rows, 10)
+
+	want := []trendingHashtag{
+		{Tag: "go", ChirpCount: 2, Score: 1.5},
+		{Tag: "c", ChirpCount: 1, Score: 1},
+		{Tag: "zig", ChirpCount: 1, Score: 1},
+		{Tag: "rust", ChirpCount: 3, Score: 0.75},
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Expected %+v, got %+v", want, got)
+	}
+
+	if top := rankTrendingHashtags(rows, 2); len(top) != 2 || top[0].Tag != "go" || top[1].Tag != "c" {
+		t.Fatalf("Expected the 2 best hashtags, got %+v", top)
+	}
+
+	if empty := rankTrendingHashtags(nil, 10); empty == nil || len(empty) != 0 {
+		t.Fatalf("Expected an empty list, got %+v", empty)
+	}
+}
+
+func TestTrendingTracker_Replace(t *testing.T) {
+	tracker := &trendingTracker{}
+
+	if hashtags, computedAt := tracker.get(); hashtags != nil || !computedAt.IsZero() {
+		t.Fatalf("Expected nothing before the first run, got %+v at %v", hashtags, computedAt)
+	}
+
+	first := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker.set([]trendingHashtag{{Tag: "go"}, {Tag: "rust"}}, first)
+
+	second := first.Add(trendingInterval)
+	tracker.set([]trendingHashtag{{Tag: "zig"}}, second)
+
+	hashtags, computedAt := tracker.get()
+	if len(hashtags) != 1 || hashtags[0].Tag != "zig" || !computedAt.Equal(second) {
+		t.Fatalf("Expected the second run to replace the first, got %+v at %v", hashtags, computedAt)
+	}
+}
+
+func TestHandlerGetTrending(t *testing.T) {
+	c := &apiConfig{trending: &trendingTracker{}}
+
+	get := func(query string) (int, trendingResponse) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		c.handlerGetTrending(w, httptest.NewRequest(http.MethodGet, "/api/trending"+query, nil))
+		var resp trendingResponse
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Error decoding the response: %v", err)
+			}
+		}
+		return w.Code, resp
+	}
+
+	code, resp := get("")
+	if code != http.StatusOK || len(resp.Hashtags) != 0 || resp.ComputedAt != nil {
+		t.Fatalf("Expected an empty list before the first run, got %d %+v", code, resp)
+	}
+
+	c.trending.set([]trendingHashtag{{Tag: "go"}, {Tag: "rust"}, {Tag: "zig"}}, time.Now().UTC())
+
+	code, resp = get("?limit=2")
+	if code != http.StatusOK || len(resp.Hashtags) != 2 || resp.Hashtags[0].Tag != "go" || resp.ComputedAt == nil {
+		t.Fatalf("Expected the 2 first hashtags, got %d %+v", code, resp)
+	}
+
+	if code, _ := get("?limit=0"); code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 for an invalid limit, got %d", code)
+	}
+}
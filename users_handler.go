@@ -1,18 +1,26 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/federicoReghini/Chirpy/internal/auth"
 	"github.com/federicoReghini/Chirpy/internal/database"
+	"github.com/federicoReghini/Chirpy/internal/lockout"
+	"github.com/federicoReghini/Chirpy/internal/mailer"
 	"github.com/google/uuid"
 )
 
 type createUserBodyRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	// Username is optional, a random one is generated when it is empty
+	Username string `json:"username"`
 }
 
 func (c *apiConfig) handlerCreateUser(w http.ResponseWriter, req *http.Request) {
@@ -29,6 +37,33 @@ func (c *apiConfig) handlerCreateUser(w http.ResponseWriter, req *http.Request)
 		return
 	}
 
+	if !mailer.ValidAddress(params.Email) {
+		marshalError(w, http.StatusBadRequest, "Invalid email")
+		return
+	}
+
+	params.Username = normalizeUsername(params.Username)
+	if params.Username == "" {
+		params.Username, err = generateUsername()
+		if err != nil {
+			marshalError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	} else if !validUsername(params.Username) {
+		marshalError(w, http.StatusBadRequest, invalidUsernameMessage)
+		return
+	}
+
+	taken, err := c.usernameTaken(req.Context(), params.Username, uuid.Nil)
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if taken {
+		marshalError(w, http.StatusConflict, "Username already taken")
+		return
+	}
+
 	params.Password, err = auth.HashPassword(params.Password)
 	if err != nil {
 		marshalError(w, 500, err.Error())
@@ -38,6 +73,7 @@ func (c *apiConfig) handlerCreateUser(w http.ResponseWriter, req *http.Request)
 	user, err := c.db.CreateUser(req.Context(), database.CreateUserParams{
 		Email:          params.Email,
 		HashedPassword: params.Password,
+		Username:       params.Username,
 	})
 
 	if err != nil {
@@ -45,64 +81,219 @@ func (c *apiConfig) handlerCreateUser(w http.ResponseWriter, req *http.Request)
 		return
 	}
 
-	dat, err := json.Marshal(user)
-	if err != nil {
-		marshalError(w, 500, err.Error())
-		return
+	// The account is created even if the email cannot be sent, the user can ask for a new link
+	if err := c.sendVerificationEmail(req.Context(), user.ID, user.Email); err != nil {
+		log.Printf("Error sending verification email to user %s: %s", user.ID, err)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(201)
-	w.Write(dat)
+	marshalOkJson(w, http.StatusCreated, newUserResponse(user))
+}
+
+type updateUserRequest struct {
+	// The fields are only changed when present
+	Email       *string `json:"email"`
+	Password    *string `json:"password"`
+	Username    *string `json:"username"`
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
+	AvatarURL   *string `json:"avatar_url"`
+	// AvatarMediaID sets the avatar to an image uploaded with POST /api/media
+	AvatarMediaID *uuid.UUID `json:"avatar_media_id"`
+	// CurrentPassword confirms the changes of the email and the password
+	CurrentPassword string `json:"current_password"`
 }
 
+// handlerUpdateUser changes the account and the profile of the authenticated user.
+// Only the fields of the body change. Changing the email or the password requires the current password.
+// A new email has to be verified, a verification link is sent to it.
+// A new password logs out the other sessions of the user, the current one stays logged in.
+// avatar_media_id sets the avatar to an image the user uploaded.
+// It returns 403 if the current password is wrong and 409 if the email or the username is used by another user.
+// It is registered as a handler for the "/api/users" endpoint with the PUT and PATCH methods.
 func (c *apiConfig) handlerUpdateUser(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
 
-	userID, err := getUserIDFromValidateJWT(c, w, req)
-	if err != nil {
-		marshalError(w, http.StatusUnauthorized, "Invalid token")
+	claims := claimsFromContext(req.Context())
+	userID := claims.UserID()
+
+	params := updateUserRequest{}
+	decoder := json.NewDecoder(req.Body)
+	if err := decoder.Decode(&params); err != nil {
+		marshalError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	// Reuse the same request struct as createUser
-	params := createUserBodyRequest{}
-	decoder := json.NewDecoder(req.Body)
-	err = decoder.Decode(&params)
-	if err != nil {
-		marshalError(w, http.StatusInternalServerError, "Something went wrong")
+	if params.AvatarMediaID != nil {
+		if params.AvatarURL != nil {
+			marshalError(w, http.StatusBadRequest, "Set avatar_url or avatar_media_id, not both")
+			return
+		}
+		avatar, err := c.db.GetMedia(req.Context(), *params.AvatarMediaID)
+		if err != nil || avatar.UserID != userID {
+			marshalError(w, http.StatusBadRequest, "Media not found")
+			return
+		}
+		// The images of a chirp are deleted with it
+		if avatar.ChirpID.Valid {
+			marshalError(w, http.StatusBadRequest, "Media is attached to a chirp")
+			return
+		}
+		avatarURL := c.mediaURL(avatar.StorageKey)
+		params.AvatarURL = &avatarURL
+	}
+
+	if params.Email == nil && params.Password == nil && params.Username == nil &&
+		params.DisplayName == nil && params.Bio == nil && params.AvatarURL == nil {
+		marshalError(w, http.StatusBadRequest, "Nothing to update")
+		return
+	}
+	if params.Username != nil {
+		*params.Username = normalizeUsername(*params.Username)
+	}
+	if msg := validateProfile(params.Username, params.DisplayName, params.Bio, params.AvatarURL); msg != "" {
+		marshalError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if params.Email != nil && !mailer.ValidAddress(*params.Email) {
+		marshalError(w, http.StatusBadRequest, "Invalid email")
+		return
+	}
+	if params.Password != nil && *params.Password == "" {
+		marshalError(w, http.StatusBadRequest, "Password cannot be empty")
 		return
 	}
 
-	// Reuse password hashing logic from createUser
-	params.Password, err = auth.HashPassword(params.Password)
+	user, err := c.db.GetUserByID(req.Context(), userID)
 	if err != nil {
-		marshalError(w, http.StatusInternalServerError, err.Error())
+		marshalError(w, http.StatusNotFound, "User not found")
 		return
 	}
 
-	// Update user with same parameter structure
-	user, err := c.db.UpdateUser(req.Context(), database.UpdateUserParams{
-		ID:             userID,
-		Email:          params.Email,
-		HashedPassword: params.Password,
-	})
+	if params.Email != nil || params.Password != nil {
+		if params.CurrentPassword == "" {
+			marshalError(w, http.StatusBadRequest, "Current password is required")
+			return
+		}
+		if err := auth.CheckPasswordHash(params.CurrentPassword, user.HashedPassword); err != nil {
+			marshalError(w, http.StatusForbidden, "Incorrect current password")
+			return
+		}
+	}
+
+	updateParams := database.UpdateUserParams{
+		ID:          userID,
+		Username:    nullString(params.Username),
+		DisplayName: nullString(params.DisplayName),
+		Bio:         nullString(params.Bio),
+		AvatarUrl:   nullString(params.AvatarURL),
+	}
 
+	if params.Username != nil {
+		taken, err := c.usernameTaken(req.Context(), *params.Username, userID)
+		if err != nil {
+			marshalError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if taken {
+			marshalError(w, http.StatusConflict, "Username already taken")
+			return
+		}
+	}
+
+	emailChanged := params.Email != nil && *params.Email != user.Email
+	if emailChanged {
+		_, err := c.db.GetUserByEmail(req.Context(), *params.Email)
+		if err == nil {
+			marshalError(w, http.StatusConflict, "Email already in use")
+			return
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			marshalError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		updateParams.Email = nullString(params.Email)
+	}
+
+	if params.Password != nil {
+		hashedPassword, err := auth.HashPassword(*params.Password)
+		if err != nil {
+			marshalError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		updateParams.HashedPassword = sql.NullString{String: hashedPassword, Valid: true}
+	}
+
+	// The password and the logout of the other sessions are saved together
+	tx, err := c.sqlDB.BeginTx(req.Context(), nil)
 	if err != nil {
-		marshalError(w, 500, "update user error: "+err.Error())
+		marshalError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	defer tx.Rollback()
+	qtx := c.db.WithTx(tx)
 
-	// Reuse the same marshaling logic from createUser
-	dat, err := json.Marshal(user)
+	user, err = qtx.UpdateUser(req.Context(), updateParams)
 	if err != nil {
-		marshalError(w, 500, err.Error())
+		marshalError(w, http.StatusInternalServerError, "update user error: "+err.Error())
+		return
+	}
+
+	if params.Password != nil {
+		err := qtx.RevokeOtherUserSessions(req.Context(), database.RevokeOtherUserSessionsParams{
+			UserID:        uuid.NullUUID{UUID: userID, Valid: true},
+			KeepSessionID: claims.SessionUUID(),
+		})
+		if err != nil {
+			marshalError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if err := qtx.DeletePasswordResetTokens(req.Context(), userID); err != nil {
+			marshalError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(200)
-	w.Write(dat)
+	// A changed email is no longer verified (see UpdateUser), a new link is sent
+	if emailChanged {
+		if err := c.sendVerificationEmail(req.Context(), user.ID, user.Email); err != nil {
+			log.Printf("Error sending verification email to user %s: %s", user.ID, err)
+		}
+	}
+
+	marshalOkJson(w, http.StatusOK, newUserResponse(user))
+}
+
+// refreshTokenDuration is how long a refresh token can be used.
+// Every refresh issues a new token valid for the same duration.
+const refreshTokenDuration = 60 * 24 * time.Hour
+
+// Locks of the logins after failed attempts. An IP address is allowed more failures
+// than an account, as several users can share it.
+// Allowed is the number of failures that lock the email or the address.
+var (
+	loginAccountPolicy = lockout.Policy{
+		Allowed:     5,
+		BaseLock:    30 * time.Second,
+		MaxLock:     15 * time.Minute,
+		ForgetAfter: time.Hour,
+	}
+	loginIPPolicy = lockout.Policy{
+		Allowed:     20,
+		BaseLock:    30 * time.Second,
+		MaxLock:     15 * time.Minute,
+		ForgetAfter: time.Hour,
+	}
+)
+
+// loginLocked responds 429 Too Many Requests to a locked login, with the seconds to wait.
+func loginLocked(w http.ResponseWriter, wait time.Duration) {
+	marshalTooManyRequests(w, wait, "Too many failed login attempts, try again later")
 }
 
 type loginRequest struct {
@@ -110,18 +301,13 @@ type loginRequest struct {
 	Email    string `json:"email"`
 }
 
-type UserWithToken struct {
-	ID           uuid.UUID `json:"id"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	Email        string    `json:"email"`
-	Token        string    `json:"token"`
-	RefreshToken string    `json:"refresh_token"`
-	IsChirpyRed  bool      `json:"is_chirpy_red,omitempty"`
-}
-
 // handlerLogin handles user login requests.
 // It checks the user's credentials and returns a JWT token if successful.
+// A wrong email and a wrong password get the same 401 response, in about the same time.
+// The attempts are counted per email and per IP address before the password is checked,
+// so parallel logins cannot get past the lock, and given back when they succeed.
+// Once the allowed failures are reached, the email or the address is locked for a time
+// that doubles with each failure, and the logins get 429 Too Many Requests with a Retry-After header.
 func (c *apiConfig) handlerLogin(w http.ResponseWriter, req *http.Request) {
 
 	defer req.Body.Close()
@@ -134,21 +320,57 @@ func (c *apiConfig) handlerLogin(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// The email is counted whether it has an account or not, so the locks do not reveal it either
+	accountKey := strings.ToLower(strings.TrimSpace(params.Email))
+	ip := clientIP(req)
+	if wait := c.loginIPLockout.Attempt(ip); wait > 0 {
+		loginLocked(w, wait)
+		return
+	}
+	if wait := c.loginAccountLockout.Attempt(accountKey); wait > 0 {
+		// No password was checked, the attempt of the address is given back
+		c.loginIPLockout.Release(ip)
+		loginLocked(w, wait)
+		return
+	}
+
 	user, err := c.db.GetUserByEmail(req.Context(), params.Email)
-	if err != nil {
-		marshalError(w, http.StatusNotFound, "User not found")
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		// Not a failure of the user, the attempts are given back
+		c.loginIPLockout.Release(ip)
+		c.loginAccountLockout.Release(accountKey)
+		marshalError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	// Check if psw is correct
-	if err := auth.CheckPasswordHash(params.Password, user.HashedPassword); err != nil {
+	if err == nil {
+		err = auth.CheckPasswordHash(params.Password, user.HashedPassword)
+	} else {
+		err = auth.CheckDummyPasswordHash(params.Password)
+	}
+	if err != nil {
 		marshalError(w, http.StatusUnauthorized, "Incorrect email or password")
 		return
 	}
+	c.loginIPLockout.Release(ip)
+	c.loginAccountLockout.Reset(accountKey)
 
-	// Create Jwt
-	token, err := auth.MakeJWT(user.ID, c.apiKey, time.Duration(60*60)*time.Second)
+	// Every login starts a new session, the session ID is the family of its refresh tokens
+	// (see handlerRefreshToken)
+	tx, err := c.sqlDB.BeginTx(req.Context(), nil)
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := c.db.WithTx(tx)
 
+	session, err := qtx.CreateSession(req.Context(), database.CreateSessionParams{
+		UserID:    user.ID,
+		UserAgent: clientUserAgent(req),
+		Ip:        clientIP(req),
+	})
 	if err != nil {
 		marshalError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -162,70 +384,147 @@ func (c *apiConfig) handlerLogin(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	c.db.CreateRefreshToken(req.Context(), database.CreateRefreshTokenParams{
-		Token: refreshToken,
+	_, err = qtx.CreateRefreshToken(req.Context(), database.CreateRefreshTokenParams{
+		TokenHash: auth.HashToken(refreshToken),
 		UserID: uuid.NullUUID{
 			UUID:  user.ID,
 			Valid: true,
 		},
-		ExpiresAt: time.Now().Add(time.Hour * 24 * 60),
+		ExpiresAt: time.Now().Add(refreshTokenDuration),
+		FamilyID:  session.ID,
 	})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 
-	usr := UserWithToken{
-		ID:           user.ID,
-		Email:        user.Email,
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
-		Token:        token,
-		RefreshToken: refreshToken,
-		IsChirpyRed:  user.IsChirpyRed,
+	if err := tx.Commit(); err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 
-	marshalOkJson(w, http.StatusOK, usr)
+	// Create Jwt
+	token, err := c.jwtKeys.MakeJWT(user.ID, user.Role, session.ID, c.jwtAudience, time.Duration(60*60)*time.Second)
 
-}
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	marshalOkJson(w, http.StatusOK, loginResponse{
+		userResponse: newUserResponse(user),
+		Token:        token,
+		RefreshToken: refreshToken,
+	})
 
-type token struct {
-	Token string `json:"token"`
 }
 
 // handlerRefreshToken handles requests to refresh the JWT token using a refresh token.
-// It checks the validity of the refresh token and returns a new JWT token if valid.
+// Refresh tokens are single use: every refresh returns a new JWT and a new refresh token,
+// and the presented refresh token is revoked.
+// The tokens issued from the same login form a family. When a refresh token that was
+// already rotated is presented again, it was leaked or stolen, so the whole family is
+// revoked and the user has to log in again.
 func (c *apiConfig) handlerRefreshToken(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
 
 	refreshToken, err := auth.GetBearerToken(req.Header)
 
+	if err != nil {
+		marshalError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	// The rotation and the new token are saved together
+	tx, err := c.sqlDB.BeginTx(req.Context(), nil)
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := c.db.WithTx(tx)
+
+	// Revoking the token is atomic, two concurrent refreshes cannot both succeed
+	refreshTokenRecord, err := qtx.RotateRefreshToken(req.Context(), auth.HashToken(refreshToken))
+	if errors.Is(err, sql.ErrNoRows) {
+		c.handleInvalidRefreshToken(w, req, refreshToken)
+		return
+	}
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// The role is read again so a role change applies from the next refresh
+	user, err := qtx.GetUserByID(req.Context(), refreshTokenRecord.UserID.UUID)
+	if err != nil {
+		marshalError(w, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	newRefreshToken, err := auth.MakeRefreshToken()
 	if err != nil {
 		marshalError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	// Check if the refresh token is valid
-	refreshTokenRecord, err := c.db.GetRefreshTokenByToken(req.Context(), refreshToken)
+	_, err = qtx.CreateRefreshToken(req.Context(), database.CreateRefreshTokenParams{
+		TokenHash: auth.HashToken(newRefreshToken),
+		UserID:    refreshTokenRecord.UserID,
+		ExpiresAt: time.Now().Add(refreshTokenDuration),
+		FamilyID:  refreshTokenRecord.FamilyID,
+	})
+	if err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 
+	err = qtx.TouchSession(req.Context(), database.TouchSessionParams{
+		ID:        refreshTokenRecord.FamilyID,
+		UserAgent: clientUserAgent(req),
+		Ip:        clientIP(req),
+	})
 	if err != nil {
-		marshalError(w, http.StatusNotFound, err.Error())
+		marshalError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	if refreshTokenRecord.RevokedAt.Valid || refreshTokenRecord.ExpiresAt.Before(time.Now()) {
-		marshalError(w, http.StatusUnauthorized, "Refresh token expired")
+	if err := tx.Commit(); err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	tkn, err := auth.MakeJWT(refreshTokenRecord.UserID.UUID, c.apiKey, time.Duration(60*60)*time.Second)
+	tkn, err := c.jwtKeys.MakeJWT(user.ID, user.Role, refreshTokenRecord.FamilyID, c.jwtAudience, time.Duration(60*60)*time.Second)
 	if err != nil {
 		marshalError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	marshalOkJson(w, http.StatusOK, token{
-		Token: tkn,
+	marshalOkJson(w, http.StatusOK, tokenResponse{
+		Token:        tkn,
+		RefreshToken: newRefreshToken,
 	})
 
 }
 
+// handleInvalidRefreshToken responds to a refresh with a token that cannot be rotated.
+// If the token was already rotated, its whole family is revoked.
+func (c *apiConfig) handleInvalidRefreshToken(w http.ResponseWriter, req *http.Request, refreshToken string) {
+	refreshTokenRecord, err := c.db.GetRefreshTokenByToken(req.Context(), auth.HashToken(refreshToken))
+	if err != nil || !refreshTokenRecord.RotatedAt.Valid {
+		marshalError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+		return
+	}
+
+	log.Printf("Refresh token reused, revoking family %s of user %s", refreshTokenRecord.FamilyID, refreshTokenRecord.UserID.UUID)
+	if err := c.db.RevokeRefreshTokenFamily(req.Context(), refreshTokenRecord.FamilyID); err != nil {
+		marshalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	marshalError(w, http.StatusUnauthorized, "Refresh token reused, log in again")
+}
+
 func (c *apiConfig) handlerRefreshTokenRevoke(w http.ResponseWriter, req *http.Request) {
 
 	defer req.Body.Close()
@@ -237,7 +536,7 @@ func (c *apiConfig) handlerRefreshTokenRevoke(w http.ResponseWriter, req *http.R
 		return
 	}
 
-	c.db.RevokeRefreshToken(req.Context(), refreshToken)
+	c.db.RevokeRefreshToken(req.Context(), auth.HashToken(refreshToken))
 
 	w.WriteHeader(http.StatusNoContent)
 
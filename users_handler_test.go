@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandlerLogin_LockedAccountKeepsTheAddress(t *testing.T) {
+	c, f, _ := newHandlerTestConfig(t)
+	f.answer("GetUserByEmail", nil)
+
+	login := func(email string) int {
+		body := `{"email": "` + email + `", "password": "wrong password"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(body))
+		req.RemoteAddr = "10.0.0.1:1234"
+		w := httptest.NewRecorder()
+		c.handlerLogin(w, req)
+		return w.Code
+	}
+
+	// Retrying a locked account does not use up the failures of the address
+	for range loginAccountPolicy.Allowed {
+		c.loginAccountLockout.Attempt("locked@example.com")
+	}
+	for i := range loginIPPolicy.Allowed + 1 {
+		if code := login("locked@example.com"); code != http.StatusTooManyRequests {
+			t.Fatalf("Expected 429 for login %d on the locked account, got %d", i+1, code)
+		}
+	}
+
+	if code := login("other@example.com"); code != http.StatusUnauthorized {
+		t.Fatalf("Expected the address to stay unlocked, got %d", code)
+	}
+}
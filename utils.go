@@ -1,12 +1,16 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
 	"log"
+	"math"
+	"net"
 	"net/http"
-
-	"github.com/federicoReghini/Chirpy/internal/auth"
-	"github.com/google/uuid"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type myError struct {
@@ -96,18 +100,56 @@ func ifErrCondition(w http.ResponseWriter, err error, statusCode int, msg string
 	}
 }
 
-func getUserIDFromValidateJWT(c *apiConfig, w http.ResponseWriter, req *http.Request) (uuid.UUID, error) {
+// maxUserAgentLength is the length the user agent of a session is truncated to
+const maxUserAgentLength = 256
 
-	token, err := auth.GetBearerToken(req.Header)
+// clientIP returns the IP address of the client that sent the request.
+// Forwarding headers are not trusted, so behind a proxy this is the address of the proxy.
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
 	if err != nil {
-		return uuid.Nil, err
+		return req.RemoteAddr
 	}
+	return host
+}
 
-	userID, err := auth.ValidateJWT(token, c.apiKey)
+// clientUserAgent returns the User-Agent header of the request, truncated to maxUserAgentLength.
+func clientUserAgent(req *http.Request) string {
+	userAgent := req.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		// Cutting may split a multi-byte character
+		userAgent = strings.ToValidUTF8(userAgent[:maxUserAgentLength], "")
+	}
+	return userAgent
+}
 
+// nullString returns a valid sql.NullString for a non-nil s, and a null one for nil.
+func nullString(s *string) sql.NullString {
+	if s == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *s, Valid: true}
+}
+
+// marshalTooManyRequests writes a 429 Too Many Requests error with the msg,
+// and a Retry-After header with the seconds to wait, rounded up.
+func marshalTooManyRequests(w http.ResponseWriter, wait time.Duration, msg string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	marshalError(w, http.StatusTooManyRequests, msg)
+}
+
+// dirInside reports whether dir is root or one of its subdirectories.
+// The paths are compared once made absolute, a path that cannot be is considered inside.
+func dirInside(dir, root string) bool {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return true
+	}
+	absRoot, err := filepath.Abs(root)
 	if err != nil {
-		return uuid.Nil, err
+		return true
 	}
 
-	return userID, nil
+	rel, err := filepath.Rel(absRoot, absDir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
 }
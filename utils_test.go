@@ -0,0 +1,28 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDirInside(t *testing.T) {
+	tests := []struct {
+		dir, root string
+		want      bool
+	}{
+		{"./static", "./static", true},
+		{"./static/mail", "./static", true},
+		{"static/../static/mail", "./static", true},
+		{"./mail", "./static", false},
+		{"./static-mail", "./static", false},
+		{"..", ".", false},
+		{"./mail", ".", true},
+		{filepath.Join(t.TempDir(), "mail"), "./static", false},
+	}
+
+	for _, tt := range tests {
+		if got := dirInside(tt.dir, tt.root); got != tt.want {
+			t.Errorf("dirInside(%q, %q) = %v, want %v", tt.dir, tt.root, got, tt.want)
+		}
+	}
+}